@@ -0,0 +1,217 @@
+--- Auto backend for scopes.nvim
+--- Builds the Treesitter tree and enriches it with LSP documentSymbol data
+--- where the two agree on a range: LSP detail strings, more precise kinds,
+--- and symbols the lang config does not cover. Whichever source is missing
+--- or too slow is skipped, so a Treesitter tree is always returned when one
+--- can be built.
+
+local ScopeNode = require("scopes.tree").ScopeNode
+
+local M = {}
+
+--- Treesitter kinds that an LSP kind is allowed to refine.
+--- Treesitter only sees syntax (e.g. Go's type_declaration is always "type"),
+--- while the server knows whether it is a struct or an interface.
+local REFINEMENTS = {
+  ["type"] = { struct = true, interface = true, class = true },
+  ["function"] = { method = true },
+  ["variable"] = { const = true },
+}
+
+--- Compare two ranges by start position.
+--- @param a ScopeNode
+--- @param b ScopeNode
+--- @return boolean
+local function by_start(a, b)
+  if a.range.start_row ~= b.range.start_row then
+    return a.range.start_row < b.range.start_row
+  end
+  return a.range.start_col < b.range.start_col
+end
+
+--- Index every built non-root node in the tree by its start row.
+--- Lazy nodes are not expanded (treesitter.lazy): the LSP symbols inside one
+--- are left to the Treesitter walk that builds its children.
+--- @param root ScopeNode
+--- @return table<number, ScopeNode[]>
+local function index_by_start_row(root)
+  local index = {}
+  local function visit(node)
+    for _, child in ipairs(node.children) do
+      local row = child.range.start_row
+      index[row] = index[row] or {}
+      table.insert(index[row], child)
+      visit(child)
+    end
+  end
+  visit(root)
+  return index
+end
+
+--- Returns true if `ts_node` may stand for an LSP symbol of `kind` under
+--- another name (gopls: `(*MyStruct).HandleRequest` for `HandleRequest`):
+--- the same kind, or one REFINEMENTS lets the LSP kind refine.
+--- @param ts_node ScopeNode
+--- @param kind string
+--- @return boolean
+local function kind_agrees(ts_node, kind)
+  local allowed = REFINEMENTS[ts_node.kind]
+  return ts_node.kind == kind or (allowed ~= nil and allowed[kind] == true)
+end
+
+--- Find the Treesitter node that describes the same symbol as `lsp_node`.
+--- Servers often start a symbol's range at its name rather than its keyword
+--- (gopls: `MyStruct struct{...}` vs `type MyStruct struct{...}`), so nodes
+--- match on start and end row, and then on name or, failing that, on kind.
+--- Anything else on those rows (a one-line `if`, a local) is left alone.
+--- @param index table<number, ScopeNode[]>
+--- @param lsp_node ScopeNode
+--- @param matched table<ScopeNode, boolean>
+--- @return ScopeNode|nil
+local function find_match(index, lsp_node, matched)
+  local best = nil
+  for _, candidate in ipairs(index[lsp_node.range.start_row] or {}) do
+    if not matched[candidate] and not candidate.is_error and candidate.range.end_row == lsp_node.range.end_row then
+      if candidate.name == lsp_node.name then
+        return candidate
+      end
+      if not best and kind_agrees(candidate, lsp_node.kind) then
+        best = candidate
+      end
+    end
+  end
+  return best
+end
+
+--- Copy LSP-only information onto a matched Treesitter node.
+--- @param ts_node ScopeNode
+--- @param lsp_node ScopeNode
+local function refine(ts_node, lsp_node)
+  if ts_node.detail == nil and lsp_node.detail ~= nil and lsp_node.detail ~= "" then
+    ts_node.detail = lsp_node.detail
+  end
+  local allowed = REFINEMENTS[ts_node.kind]
+  if allowed and allowed[lsp_node.kind] then
+    ts_node.kind = lsp_node.kind
+  end
+end
+
+--- Find the deepest built node under `node` whose range contains `range`.
+--- Returns `node` itself when no child contains it. Lazy nodes are not
+--- expanded, so the result may be one whose children are not built yet.
+--- @param node ScopeNode
+--- @param range table
+--- @return ScopeNode
+local function deepest_container(node, range)
+  for _, child in ipairs(node.children) do
+    if child:contains(range) then
+      return deepest_container(child, range)
+    end
+  end
+  return node
+end
+
+--- Insert `node` under `parent` in document order. Existing children of
+--- `parent` that fall inside `node` are moved beneath it, so an LSP-only
+--- container (e.g. a class the lang config missed) wraps its members.
+--- @param parent ScopeNode
+--- @param node ScopeNode
+local function adopt(parent, node)
+  local kept = {}
+  for _, sibling in ipairs(parent.children) do
+    if node:contains(sibling.range) then
+      node:add_child(sibling)
+    else
+      table.insert(kept, sibling)
+    end
+  end
+  parent.children = kept
+  parent:add_child(node)
+  table.sort(parent.children, by_start)
+end
+
+--- Merge an LSP tree into a Treesitter tree in place. Only nodes already
+--- built take part: a symbol inside a lazy node that is not expanded yet is
+--- skipped rather than forcing the expansion.
+--- @param ts_tree ScopeTree
+--- @param lsp_tree ScopeTree
+--- @return ScopeTree ts_tree, with source set to "treesitter+lsp"
+function M.merge(ts_tree, lsp_tree)
+  local index = index_by_start_row(ts_tree.root)
+  local matched = {}
+
+  local function visit(lsp_node)
+    local ts_node = find_match(index, lsp_node, matched)
+    if ts_node then
+      matched[ts_node] = true
+      refine(ts_node, lsp_node)
+    else
+      local parent = deepest_container(ts_tree.root, lsp_node.range)
+      if parent:is_deferred() then
+        return
+      end
+      adopt(
+        parent,
+        ScopeNode.new({
+          name = lsp_node.name,
+          kind = lsp_node.kind,
+          range = lsp_node.range,
+          detail = lsp_node.detail,
+          lang = lsp_node.lang,
+        })
+      )
+    end
+    for _, child in ipairs(lsp_node.children) do
+      visit(child)
+    end
+  end
+
+  for _, child in ipairs(lsp_tree.root.children) do
+    visit(child)
+  end
+
+  ts_tree.source = "treesitter+lsp"
+  return ts_tree
+end
+
+--- Build a ScopeTree for `bufnr` from Treesitter, enriched with LSP data.
+--- The LSP request is synchronous and bounded by lsp.merge_timeout_ms, so
+--- the build blocks that long at most; a missing or slow server leaves the
+--- Treesitter tree untouched.
+--- @param bufnr number
+--- @param opts? {lang_config?: table, previous?: ScopeTree}
+--- @return ScopeTree|nil
+function M.build(bufnr, opts)
+  opts = opts or {}
+  local lsp = require("scopes.backends.lsp")
+
+  -- Quiet: without a parser the LSP and indent backends take over.
+  local ts_tree =
+    require("scopes.backends.treesitter").build(bufnr, opts.lang_config, { previous = opts.previous, silent = true })
+  if not ts_tree then
+    return lsp.build(bufnr, { silent = true })
+  end
+
+  return M.enrich(ts_tree)
+end
+
+--- Merge LSP data into an already built Treesitter tree, if a server answers
+--- within lsp.merge_timeout_ms. Used by build() and by async builds once the
+--- Treesitter walk has finished.
+--- @param ts_tree ScopeTree
+--- @return ScopeTree
+function M.enrich(ts_tree)
+  -- A stale tree was merged when it was first built.
+  if ts_tree.stale then
+    return ts_tree
+  end
+  local cfg = require("scopes.config").get()
+  local lsp_tree =
+    require("scopes.backends.lsp").build(ts_tree.bufnr, { silent = true, timeout_ms = cfg.lsp.merge_timeout_ms })
+  if not lsp_tree then
+    return ts_tree
+  end
+  return M.merge(ts_tree, lsp_tree)
+end
+
+return M
@@ -0,0 +1,95 @@
+--- Indentation backend for scopes.nvim
+--- Builds a ScopeTree from leading whitespace alone, for buffers that have
+--- neither a Treesitter parser nor a language server (notes, log dumps,
+--- ad-hoc DSLs). Every non-blank line is a node named after its text; the
+--- more-indented lines that follow it become its children.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local ScopeTree = tree_mod.ScopeTree
+
+local M = {}
+
+--- Measure the indentation of `line` in display columns, expanding tabs.
+--- @param line string
+--- @param tabstop number
+--- @return number width, number bytes  display width and byte length of the leading whitespace
+local function indent_of(line, tabstop)
+  local width = 0
+  local bytes = 0
+  for i = 1, #line do
+    local c = line:sub(i, i)
+    if c == " " then
+      width = width + 1
+    elseif c == "\t" then
+      width = width + tabstop - (width % tabstop)
+    else
+      break
+    end
+    bytes = i
+  end
+  return width, bytes
+end
+
+--- Build a ScopeTree for `bufnr` from indentation levels.
+--- Blank lines are skipped and never end a block.
+--- @param bufnr number
+--- @return ScopeTree
+function M.build(bufnr)
+  local lines = vim.api.nvim_buf_get_lines(bufnr, 0, -1, false)
+  local tabstop = vim.api.nvim_get_option_value("tabstop", { buf = bufnr })
+
+  local file_name = vim.fn.fnamemodify(vim.api.nvim_buf_get_name(bufnr), ":t")
+  if file_name == "" then
+    file_name = "[unnamed]"
+  end
+
+  local filetype = vim.api.nvim_get_option_value("filetype", { buf = bufnr })
+  local lang = filetype ~= "" and filetype or "text"
+
+  local root = ScopeNode.new({
+    name = file_name,
+    kind = "file",
+    range = { start_row = 0, start_col = 0, end_row = #lines, end_col = 0 },
+    lang = lang,
+  })
+
+  -- Open blocks from outermost to innermost; the root sits below any indent.
+  local stack = { { node = root, indent = -1 } }
+
+  for i, line in ipairs(lines) do
+    local text = vim.trim(line)
+    if text ~= "" then
+      local row = i - 1
+      local indent, start_col = indent_of(line, tabstop)
+
+      while stack[#stack].indent >= indent do
+        table.remove(stack)
+      end
+
+      -- Every open block extends down to the last line it contains.
+      for j = 2, #stack do
+        stack[j].node.range.end_row = row
+        stack[j].node.range.end_col = #line
+      end
+
+      local node = ScopeNode.new({
+        name = text,
+        kind = "block",
+        range = { start_row = row, start_col = start_col, end_row = row, end_col = #line },
+        lang = lang,
+      })
+      stack[#stack].node:add_child(node)
+      table.insert(stack, { node = node, indent = indent })
+    end
+  end
+
+  return ScopeTree.new({
+    root = root,
+    source = "indent",
+    bufnr = bufnr,
+    lang = lang,
+  })
+end
+
+return M
@@ -0,0 +1,229 @@
+--- LSP backend for scopes.nvim
+--- Requests textDocument/documentSymbol from the language servers attached
+--- to a buffer and converts the response into a ScopeTree.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local ScopeTree = tree_mod.ScopeTree
+
+local M = {}
+
+local METHOD = "textDocument/documentSymbol"
+
+--- Maps LSP SymbolKind enum values to our internal kind strings.
+--- Kinds with no close equivalent collapse onto the nearest one
+--- (e.g. Field/Property → "variable", EnumMember → "const").
+M.kind_map = {
+  [1] = "file", -- File
+  [2] = "module", -- Module
+  [3] = "module", -- Namespace
+  [4] = "module", -- Package
+  [5] = "class", -- Class
+  [6] = "method", -- Method
+  [7] = "variable", -- Property
+  [8] = "variable", -- Field
+  [9] = "method", -- Constructor
+  [10] = "type", -- Enum
+  [11] = "interface", -- Interface
+  [12] = "function", -- Function
+  [13] = "variable", -- Variable
+  [14] = "const", -- Constant
+  [15] = "const", -- String
+  [16] = "const", -- Number
+  [17] = "const", -- Boolean
+  [18] = "variable", -- Array
+  [19] = "block", -- Object
+  [20] = "variable", -- Key
+  [21] = "const", -- Null
+  [22] = "const", -- EnumMember
+  [23] = "struct", -- Struct
+  [24] = "variable", -- Event
+  [25] = "function", -- Operator
+  [26] = "type", -- TypeParameter
+}
+
+--- Convert an LSP position's character offset to a byte column.
+--- LSP columns are counted in the client's offset encoding (UTF-16 by default).
+--- Offsets past the end of the line are clamped to it.
+--- @param bufnr number
+--- @param position {line: number, character: number}
+--- @param offset_encoding string
+--- @return number
+local function to_byte_col(bufnr, position, offset_encoding)
+  if offset_encoding == "utf-8" then
+    return position.character
+  end
+  local line = vim.api.nvim_buf_get_lines(bufnr, position.line, position.line + 1, false)[1]
+  if not line then
+    return position.character
+  end
+  local ok, col
+  if vim.fn.has("nvim-0.11") == 1 then
+    ok, col = pcall(vim.str_byteindex, line, offset_encoding, position.character, false)
+  else
+    -- Neovim 0.10: str_byteindex(str, index, use_utf16), counting UTF-32 otherwise.
+    ok, col = pcall(vim.str_byteindex, line, position.character, offset_encoding == "utf-16")
+  end
+  if ok and type(col) == "number" then
+    return col
+  end
+  return #line
+end
+
+--- Convert an LSP Range into a ScopeNode range.
+--- @param bufnr number
+--- @param lsp_range {start: table, end: table}
+--- @param offset_encoding string
+--- @return {start_row: number, start_col: number, end_row: number, end_col: number}
+local function get_range(bufnr, lsp_range, offset_encoding)
+  return {
+    start_row = lsp_range.start.line,
+    start_col = to_byte_col(bufnr, lsp_range.start, offset_encoding),
+    end_row = lsp_range["end"].line,
+    end_col = to_byte_col(bufnr, lsp_range["end"], offset_encoding),
+  }
+end
+
+--- Return a copy of `symbols` sorted by start position.
+--- Servers are not required to return symbols in document order.
+--- @param symbols table[]
+--- @param range_of fun(sym: table): table
+--- @return table[]
+local function sorted_by_start(symbols, range_of)
+  local copy = vim.list_extend({}, symbols)
+  table.sort(copy, function(a, b)
+    local ra, rb = range_of(a).start, range_of(b).start
+    if ra.line ~= rb.line then
+      return ra.line < rb.line
+    end
+    return ra.character < rb.character
+  end)
+  return copy
+end
+
+--- Recursively add hierarchical DocumentSymbols under `parent`.
+--- @param symbols table[]  DocumentSymbol[]
+--- @param parent ScopeNode
+--- @param bufnr number
+--- @param offset_encoding string
+local function add_document_symbols(symbols, parent, bufnr, offset_encoding)
+  local lang = parent.lang
+  local range_of = function(sym)
+    return sym.range
+  end
+  for _, sym in ipairs(sorted_by_start(symbols, range_of)) do
+    local node = ScopeNode.new({
+      name = sym.name,
+      kind = M.kind_map[sym.kind] or "variable",
+      range = get_range(bufnr, sym.range, offset_encoding),
+      detail = sym.detail,
+      lang = lang,
+    })
+    parent:add_child(node)
+    if sym.children and #sym.children > 0 then
+      add_document_symbols(sym.children, node, bufnr, offset_encoding)
+    end
+  end
+end
+
+--- Add flat SymbolInformation entries directly under `parent`.
+--- @param symbols table[]  SymbolInformation[]
+--- @param parent ScopeNode
+--- @param bufnr number
+--- @param offset_encoding string
+local function add_symbol_information(symbols, parent, bufnr, offset_encoding)
+  local lang = parent.lang
+  local range_of = function(sym)
+    return sym.location.range
+  end
+  for _, sym in ipairs(sorted_by_start(symbols, range_of)) do
+    parent:add_child(ScopeNode.new({
+      name = sym.name,
+      kind = M.kind_map[sym.kind] or "variable",
+      range = get_range(bufnr, sym.location.range, offset_encoding),
+      detail = sym.containerName,
+      lang = lang,
+    }))
+  end
+end
+
+--- Convert a documentSymbol result into a ScopeTree.
+--- Accepts both hierarchical DocumentSymbol[] and flat SymbolInformation[].
+--- @param result table[]
+--- @param bufnr number
+--- @param offset_encoding? string  defaults to "utf-16"
+--- @return ScopeTree
+function M.convert(result, bufnr, offset_encoding)
+  offset_encoding = offset_encoding or "utf-16"
+
+  local file_name = vim.fn.fnamemodify(vim.api.nvim_buf_get_name(bufnr), ":t")
+  if file_name == "" then
+    file_name = "[unnamed]"
+  end
+
+  local lang = vim.api.nvim_get_option_value("filetype", { buf = bufnr })
+  local root = ScopeNode.new({
+    name = file_name,
+    kind = "file",
+    range = {
+      start_row = 0,
+      start_col = 0,
+      end_row = vim.api.nvim_buf_line_count(bufnr),
+      end_col = 0,
+    },
+    lang = lang,
+  })
+
+  if result[1] and result[1].location then
+    add_symbol_information(result, root, bufnr, offset_encoding)
+  else
+    add_document_symbols(result, root, bufnr, offset_encoding)
+  end
+
+  return ScopeTree.new({
+    root = root,
+    source = "lsp",
+    bufnr = bufnr,
+    lang = lang,
+  })
+end
+
+--- Build a ScopeTree for `bufnr` from the attached language servers.
+--- Blocks for at most `timeout_ms` (defaults to config lsp.timeout_ms).
+--- Returns nil when no attached client supports documentSymbol, the request
+--- fails, or it times out.
+--- @param bufnr number
+--- @param opts? {timeout_ms?: number, silent?: boolean}
+--- @return ScopeTree|nil
+function M.build(bufnr, opts)
+  opts = opts or {}
+  local cfg = require("scopes.config").get()
+
+  local clients = vim.lsp.get_clients({ bufnr = bufnr, method = METHOD })
+  if #clients == 0 then
+    if not opts.silent then
+      vim.notify("scopes.nvim: no LSP client with documentSymbol support for buffer " .. bufnr, vim.log.levels.WARN)
+    end
+    return nil
+  end
+
+  local params = { textDocument = vim.lsp.util.make_text_document_params(bufnr) }
+  local responses, err = vim.lsp.buf_request_sync(bufnr, METHOD, params, opts.timeout_ms or cfg.lsp.timeout_ms)
+  if not responses then
+    if not opts.silent then
+      vim.notify("scopes.nvim: LSP documentSymbol request failed: " .. tostring(err), vim.log.levels.WARN)
+    end
+    return nil
+  end
+
+  for _, client in ipairs(clients) do
+    local response = responses[client.id]
+    if response and not response.err and type(response.result) == "table" then
+      return M.convert(response.result, bufnr, client.offset_encoding)
+    end
+  end
+
+  return nil
+end
+
+return M
@@ -6,6 +6,7 @@ local tree_mod = require("scopes.tree")
 local ScopeNode = tree_mod.ScopeNode
 local ScopeTree = tree_mod.ScopeTree
 local lang_config_mod = require("scopes.lang_config")
+local log = require("scopes.log")
 
 local M = {}
 
@@ -33,48 +34,240 @@ local function get_range(ts_node)
   }
 end
 
+-- Kind each ScopeNode had when the walk created it, before any LSP refinement.
+-- Only nodes in this table are candidates for reuse by an incremental rebuild.
+local _walked = setmetatable({}, { __mode = "k" })
+
+--- @class scopes.WalkHooks
+--- @field reuse? fun(range: table, kind: string, lang: string): ScopeNode|nil  Returns an existing node to attach instead of walking the Treesitter subtree again.
+--- @field tick? fun()  Called once per Treesitter node visited; may yield the running coroutine.
+--- @field stop? fun(ts_node: TSNode): boolean  Called before each Treesitter node is visited; once it returns true the whole walk unwinds.
+--- @field lazy? boolean  Defer each node's children until ScopeNode:expand().
+--- @field injections? scopes.Injection[]  Injected regions to attach as lazy nodes expand.
+
+--- @class scopes.Injection
+--- @field lang string
+--- @field config LangConfig
+--- @field root TSNode
+--- @field range table
+--- @field attached boolean
+
+--- Returns true if any not yet attached injected region lies inside `node`.
+--- @param injections? scopes.Injection[]
+--- @param node ScopeNode
+--- @return boolean
+local function has_injection(injections, node)
+  for _, injection in ipairs(injections or {}) do
+    if not injection.attached and node:contains(injection.range) then
+      return true
+    end
+  end
+  return false
+end
+
+-- name_getter errors seen this session, per language and node type:
+-- { [lang] = { [node_type] = {count: number, err: string, reported: boolean} } }
+local _name_failures = {}
+
+--- Name a node with the lang config's getter, isolating errors: a getter that
+--- throws names the node after its type with a "[?]" marker, and the failure
+--- is recorded for report_name_failures().
+--- @param lang_config LangConfig
+--- @param ts_node TSNode
+--- @param node_type string
+--- @param bufnr number
+--- @param lang string
+--- @return string
+local function name_of(lang_config, ts_node, node_type, bufnr, lang)
+  local ok, name = pcall(lang_config.get_name, ts_node, bufnr)
+  if ok then
+    if type(name) == "string" and name ~= "" then
+      return name
+    end
+    return node_type
+  end
+  _name_failures[lang] = _name_failures[lang] or {}
+  local failure = _name_failures[lang][node_type]
+  if not failure then
+    failure = { count = 0, err = tostring(name), reported = false }
+    _name_failures[lang][node_type] = failure
+  end
+  failure.count = failure.count + 1
+  return node_type .. " [?]"
+end
+
+--- Emit one WARN listing the name_getter failures not reported yet, one line
+--- per language and node type with the first error seen.
+local function report_name_failures()
+  local lines = {}
+  for lang, by_type in pairs(_name_failures) do
+    for node_type, failure in pairs(by_type) do
+      if not failure.reported then
+        failure.reported = true
+        table.insert(lines, string.format("  %s %s (%d nodes): %s", lang, node_type, failure.count, failure.err))
+      end
+    end
+  end
+  if #lines > 0 then
+    table.sort(lines)
+    vim.notify(
+      "scopes.nvim: name_getter failed, nodes shown as '<type> [?]':\n" .. table.concat(lines, "\n"),
+      vim.log.levels.WARN
+    )
+  end
+end
+
+--- Name an ERROR node after the first line of its source text, so a
+--- half-typed statement reads as itself rather than a bare "[error]".
+--- @param ts_node TSNode
+--- @param bufnr number
+--- @return string
+local function error_name(ts_node, bufnr)
+  local ok, text = pcall(vim.treesitter.get_node_text, ts_node, bufnr)
+  local first_line = ok and vim.trim(vim.split(text, "\n", { plain = true })[1]) or ""
+  if first_line == "" then
+    return "[error]"
+  end
+  return first_line
+end
+
+local attach_lazy_injections
+
 --- Recursively walk the Treesitter tree and build ScopeNodes.
 --- @param ts_node TSNode
 --- @param parent_scope ScopeNode
 --- @param lang_config LangConfig
 --- @param bufnr number
-local function walk(ts_node, parent_scope, lang_config, bufnr)
+--- @param lang string  recorded on every node created
+--- @param hooks? scopes.WalkHooks
+local function walk(ts_node, parent_scope, lang_config, bufnr, lang, hooks)
+  local reuse = hooks and hooks.reuse
+  local tick = hooks and hooks.tick
+  local stop = hooks and hooks.stop
+  local lazy = hooks and hooks.lazy
+  local injections = hooks and hooks.injections
   local scope_set = to_set(lang_config.scope_types)
   local symbol_set = to_set(lang_config.symbol_types)
 
+  --- Classify a node as "scope", "symbol", or nil (transparent).
+  --- @param child TSNode
+  --- @param child_type string
+  --- @return "scope"|"symbol"|nil
+  local function category_of(child, child_type)
+    if lang_config.classify then
+      return lang_config.classify(child)
+    end
+    if scope_set[child_type] then
+      return "scope"
+    end
+    if symbol_set[child_type] then
+      return "symbol"
+    end
+    return nil
+  end
+
+  --- Resolve the kind string for a node.
+  --- @param child TSNode
+  --- @param child_type string
+  --- @return string
+  local function kind_of(child, child_type)
+    local kind = lang_config.get_kind and lang_config.get_kind(child)
+    return kind or (lang_config.kind_map and lang_config.kind_map[child_type]) or child_type
+  end
+
+  --- Returns true if walking `ts_node` would create at least one node.
+  --- Stops at the first one found.
+  --- @param ts_node TSNode
+  --- @return boolean
+  local function has_nodes(ts_node)
+    for child in ts_node:iter_children() do
+      local child_type = child:type()
+      if child_type == "ERROR" or category_of(child, child_type) or has_nodes(child) then
+        return true
+      end
+    end
+    return false
+  end
+
+  local r_walk
+
+  --- Build the nodes below `ts_node` into `scope_node`, now or on first expand().
+  --- @param ts_node TSNode
+  --- @param scope_node ScopeNode
+  --- @param walk_children boolean  false for symbols, which only receive injected regions
+  local function descend(ts_node, scope_node, walk_children)
+    if not lazy then
+      if walk_children then
+        r_walk(ts_node, scope_node)
+      end
+      return
+    end
+    local has_children = (walk_children and has_nodes(ts_node)) or has_injection(injections, scope_node)
+    if has_children then
+      scope_node:defer(function()
+        if walk_children then
+          walk(ts_node, scope_node, lang_config, bufnr, lang, { lazy = true, injections = injections })
+        end
+        attach_lazy_injections(scope_node, injections, bufnr)
+        report_name_failures()
+      end, true)
+    end
+  end
+
   --- @param ts_node TSNode
   --- @param parent_scope ScopeNode
-  local function r_walk(ts_node, parent_scope)
+  function r_walk(ts_node, parent_scope)
     for child in ts_node:iter_children() do
+      if stop and stop(child) then
+        return
+      end
+      if tick then
+        tick()
+      end
       local child_type = child:type()
       -- TODO: Need to handle case where a node may or may not be scoped (like nested structs in go)
       if child_type == "ERROR" then
         local error_node = ScopeNode.new({
-          name = "[error]",
+          name = error_name(child, bufnr),
           kind = "block",
           range = get_range(child),
           is_error = true,
+          lang = lang,
         })
         parent_scope:add_child(error_node)
-        r_walk(child, error_node)
-      elseif scope_set[child_type] then
-        local scope_node = ScopeNode.new({
-          name = lang_config.get_name(child, bufnr),
-          kind = lang_config.kind_map and lang_config.kind_map[child_type] or child_type,
-          range = get_range(child),
-        })
-        parent_scope:add_child(scope_node)
-        r_walk(child, scope_node)
-      elseif symbol_set[child_type] then
-        local symbol_node = ScopeNode.new({
-          name = lang_config.get_name(child, bufnr),
-          kind = lang_config.kind_map and lang_config.kind_map[child_type] or child_type,
-          range = get_range(child),
-        })
-        parent_scope:add_child(symbol_node)
+        descend(child, error_node, true)
       else
-        -- Transparent pass-through: recurse without creating a node
-        r_walk(child, parent_scope)
+        local category = category_of(child, child_type)
+        local reused = nil
+        if category and reuse then
+          reused = reuse(get_range(child), kind_of(child, child_type), lang)
+        end
+        if reused then
+          parent_scope:add_child(reused)
+        elseif category == "scope" then
+          local scope_node = ScopeNode.new({
+            name = name_of(lang_config, child, child_type, bufnr, lang),
+            kind = kind_of(child, child_type),
+            range = get_range(child),
+            lang = lang,
+          })
+          _walked[scope_node] = scope_node.kind
+          parent_scope:add_child(scope_node)
+          descend(child, scope_node, true)
+        elseif category == "symbol" then
+          local symbol_node = ScopeNode.new({
+            name = name_of(lang_config, child, child_type, bufnr, lang),
+            kind = kind_of(child, child_type),
+            range = get_range(child),
+            lang = lang,
+          })
+          _walked[symbol_node] = symbol_node.kind
+          parent_scope:add_child(symbol_node)
+          descend(child, symbol_node, false)
+        else
+          -- Transparent pass-through: recurse without creating a node
+          r_walk(child, parent_scope)
+        end
       end
     end
   end
@@ -82,11 +275,422 @@ local function walk(ts_node, parent_scope, lang_config, bufnr)
   r_walk(ts_node, parent_scope)
 end
 
---- Build a ScopeTree from a buffer's Treesitter parse tree.
+--- Compare two ScopeNodes by start position.
+--- @param a ScopeNode
+--- @param b ScopeNode
+--- @return boolean
+local function by_start(a, b)
+  if a.range.start_row ~= b.range.start_row then
+    return a.range.start_row < b.range.start_row
+  end
+  return a.range.start_col < b.range.start_col
+end
+
+--- Find the deepest node under `node` whose range contains `range`.
+--- @param node ScopeNode
+--- @param range table
+--- @return ScopeNode
+local function deepest_container(node, range)
+  for _, child in ipairs(node.children) do
+    if child:contains(range) then
+      return deepest_container(child, range)
+    end
+  end
+  return node
+end
+
+--- Returns true if `node` or one of its ancestors is in `set`.
+--- @param node ScopeNode
+--- @param set table<ScopeNode, boolean>
+--- @return boolean
+local function within(node, set)
+  while node do
+    if set[node] then
+      return true
+    end
+    node = node.parent
+  end
+  return false
+end
+
+--- Walk every injected language region below `ltree` and attach its nodes
+--- under the deepest ScopeNode that contains the region (e.g. a Markdown code
+--- fence, or the Go function holding an SQL string). Only languages with an
+--- explicit lang config are walked; helper grammars such as comment, regex or
+--- markdown_inline would otherwise add noise through the generic fallback.
+--- Regions inside a reused subtree are skipped: their nodes came along with it.
+--- @param ltree vim.treesitter.LanguageTree
+--- @param root ScopeNode
+--- @param bufnr number
+--- @param hooks? scopes.WalkHooks
+--- @param reused? table<ScopeNode, boolean>
+local function attach_injections(ltree, root, bufnr, hooks, reused)
+  for child_lang, child_ltree in pairs(ltree:children()) do
+    local child_config = lang_config_mod.load(child_lang)
+    if child_config then
+      for _, ts_tree in pairs(child_ltree:trees()) do
+        local ts_root = ts_tree:root()
+        local host = deepest_container(root, get_range(ts_root))
+        if not (reused and within(host, reused)) then
+          if child_config.prepare then
+            child_config.prepare(ts_root, bufnr)
+          end
+          walk(ts_root, host, child_config, bufnr, child_lang, hooks)
+          table.sort(host.children, by_start)
+        end
+      end
+    end
+    attach_injections(child_ltree, root, bufnr, hooks, reused)
+  end
+end
+
+--- Collect every injected region below `ltree` that has an explicit lang
+--- config, for attaching lazily. Each region gets its own config (and
+--- prepare() call), since query-based configs keep per-tree state.
+--- @param ltree vim.treesitter.LanguageTree
+--- @param bufnr number
+--- @param out? scopes.Injection[]
+--- @return scopes.Injection[]
+local function collect_injections(ltree, bufnr, out)
+  out = out or {}
+  for child_lang, child_ltree in pairs(ltree:children()) do
+    for _, ts_tree in pairs(child_ltree:trees()) do
+      local child_config = lang_config_mod.load(child_lang)
+      if not child_config then
+        break
+      end
+      local ts_root = ts_tree:root()
+      if child_config.prepare then
+        child_config.prepare(ts_root, bufnr)
+      end
+      table.insert(out, {
+        lang = child_lang,
+        config = child_config,
+        root = ts_root,
+        range = get_range(ts_root),
+        attached = false,
+      })
+    end
+    collect_injections(child_ltree, bufnr, out)
+  end
+  return out
+end
+
+--- Attach the injected regions that `node` contains but none of its
+--- (now built) children do. Regions inside a child wait for that child's expand().
+--- @param node ScopeNode
+--- @param injections? scopes.Injection[]
+--- @param bufnr number
+function attach_lazy_injections(node, injections, bufnr)
+  local attached = false
+  for _, injection in ipairs(injections or {}) do
+    if not injection.attached and node:contains(injection.range) then
+      local inside_child = false
+      for _, child in ipairs(node.children) do
+        if child:contains(injection.range) then
+          inside_child = true
+          break
+        end
+      end
+      if not inside_child then
+        injection.attached = true
+        attached = true
+        walk(injection.root, node, injection.config, bufnr, injection.lang, { lazy = true, injections = injections })
+      end
+    end
+  end
+  if attached then
+    table.sort(node.children, by_start)
+  end
+end
+
+-- Edits and Treesitter changed ranges seen since the last build, per buffer.
+-- { tree, parser, lang_config, edits = Edit[], changes = Range6[], reloaded = boolean }
+local _tracked = {}
+
+--- Start (or restart) recording edits to `bufnr` relative to `scope_tree`.
+--- Buffer and parser callbacks cannot be removed, so callbacks belonging to a
+--- replaced state do nothing (and buffer callbacks detach themselves).
 --- @param bufnr number
+--- @param scope_tree ScopeTree  the tree just built
+--- @param parser vim.treesitter.LanguageTree
+--- @param lang_config? LangConfig  explicit config passed to build(), if any
+local function track(bufnr, scope_tree, parser, lang_config)
+  local state = _tracked[bufnr]
+  if state and state.parser == parser then
+    state.tree, state.lang_config = scope_tree, lang_config
+    state.edits, state.changes, state.reloaded = {}, {}, false
+    return
+  end
+
+  state = { tree = scope_tree, parser = parser, lang_config = lang_config, edits = {}, changes = {}, reloaded = false }
+  _tracked[bufnr] = state
+
+  -- Extents are relative to the start: a column is only an offset when the row extent is 0.
+  vim.api.nvim_buf_attach(bufnr, false, {
+    on_bytes = function(_, _, _, start_row, start_col, _, old_rows, old_cols, _, new_rows, new_cols)
+      if _tracked[bufnr] ~= state then
+        return true
+      end
+      table.insert(state.edits, {
+        start_row = start_row,
+        start_col = start_col,
+        old_end_row = start_row + old_rows,
+        old_end_col = (old_rows == 0 and start_col or 0) + old_cols,
+        new_end_row = start_row + new_rows,
+        new_end_col = (new_rows == 0 and start_col or 0) + new_cols,
+      })
+    end,
+    on_reload = function()
+      state.reloaded = true
+    end,
+    on_detach = function()
+      if _tracked[bufnr] == state then
+        _tracked[bufnr] = nil
+      end
+    end,
+  })
+  parser:register_cbs({
+    on_changedtree = function(changes)
+      if _tracked[bufnr] == state then
+        vim.list_extend(state.changes, changes)
+      end
+    end,
+  }, true)
+end
+
+local LAST_GOOD_NS = vim.api.nvim_create_namespace("scopes_last_good")
+
+-- Last tree built from an error-free parse, per buffer, for treesitter.keep_last_good.
+-- { tree, lang, lang_config, marks = { [ScopeNode] = extmark_id } }
+local _last_good = {}
+
+--- Remember `scope_tree` as the last good tree for `bufnr`, placing an extmark
+--- over every node's range so the ranges can follow later edits.
+--- @param bufnr number
+--- @param scope_tree ScopeTree
+--- @param lang_config? LangConfig  explicit config passed to build(), if any
+local function remember(bufnr, scope_tree, lang_config)
+  vim.api.nvim_buf_clear_namespace(bufnr, LAST_GOOD_NS, 0, -1)
+  local marks = {}
+  local function mark(node)
+    local r = node.range
+    marks[node] = vim.api.nvim_buf_set_extmark(bufnr, LAST_GOOD_NS, r.start_row, r.start_col, {
+      end_row = r.end_row,
+      end_col = r.end_col,
+      strict = false,
+    })
+    for _, child in ipairs(node.children) do
+      mark(child)
+    end
+  end
+  mark(scope_tree.root)
+  _last_good[bufnr] = { tree = scope_tree, lang = scope_tree.lang, lang_config = lang_config, marks = marks }
+end
+
+--- Return a copy of the last good tree for `bufnr` with every range moved to
+--- where its extmark is now, or nil when there is none for this language and
+--- config. The remembered tree itself is left alone: the cache may still hold
+--- it under the changedtick it was built for.
+--- @param bufnr number
+--- @param lang string
+--- @param lang_config? LangConfig
 --- @return ScopeTree|nil
-function M.build(bufnr)
+local function last_good(bufnr, lang, lang_config)
+  local entry = _last_good[bufnr]
+  if not entry or entry.lang ~= lang or entry.lang_config ~= lang_config then
+    return nil
+  end
+
+  local function copy(node, parent)
+    local moved = setmetatable({}, getmetatable(node))
+    for key, value in pairs(node) do
+      moved[key] = value
+    end
+    moved.range = vim.deepcopy(node.range)
+    local id = entry.marks[node]
+    local pos = id and vim.api.nvim_buf_get_extmark_by_id(bufnr, LAST_GOOD_NS, id, { details = true })
+    if pos and pos[1] then
+      moved.range.start_row, moved.range.start_col = pos[1], pos[2]
+      moved.range.end_row, moved.range.end_col = pos[3].end_row or pos[1], pos[3].end_col or pos[2]
+    end
+    moved.parent = parent
+    moved.children = {}
+    for _, child in ipairs(node.children) do
+      table.insert(moved.children, copy(child, moved))
+    end
+    return moved
+  end
+
+  local stale = ScopeTree.new({
+    root = copy(entry.tree.root, nil),
+    source = entry.tree.source,
+    bufnr = bufnr,
+    lang = entry.tree.lang,
+  })
+  stale.stale = true
+  return stale
+end
+
+--- Forget the last good tree for `bufnr` and its extmarks.
+--- @param bufnr number
+function M.forget(bufnr)
+  if _last_good[bufnr] then
+    _last_good[bufnr] = nil
+    if vim.api.nvim_buf_is_valid(bufnr) then
+      vim.api.nvim_buf_clear_namespace(bufnr, LAST_GOOD_NS, 0, -1)
+    end
+  end
+end
+
+--- Returns true if (r1, c1) comes strictly before (r2, c2).
+local function before(r1, c1, r2, c2)
+  return r1 < r2 or (r1 == r2 and c1 < c2)
+end
+
+--- Move a range through one buffer edit.
+--- Returns nil when the edit touches the range, i.e. its text may have changed.
+--- @param range table
+--- @param edit table
+--- @return table|nil
+local function apply_edit(range, edit)
+  if before(range.end_row, range.end_col, edit.start_row, edit.start_col) then
+    return range
+  end
+  if not before(edit.old_end_row, edit.old_end_col, range.start_row, range.start_col) then
+    return nil
+  end
+  local function shift(row, col)
+    if row == edit.old_end_row then
+      return edit.new_end_row, edit.new_end_col + (col - edit.old_end_col)
+    end
+    return row + edit.new_end_row - edit.old_end_row, col
+  end
+  local start_row, start_col = shift(range.start_row, range.start_col)
+  local end_row, end_col = shift(range.end_row, range.end_col)
+  return { start_row = start_row, start_col = start_col, end_row = end_row, end_col = end_col }
+end
+
+--- Returns true if `range` overlaps a Treesitter Range6 (inclusive).
+--- @param range table
+--- @param change Range6  {start_row, start_col, start_byte, end_row, end_col, end_byte}
+--- @return boolean
+local function overlaps(range, change)
+  return not before(range.end_row, range.end_col, change[1], change[2])
+    and not before(change[4], change[5], range.start_row, range.start_col)
+end
+
+--- Prepare reuse of the nodes in `previous` that no edit or changed range touched.
+--- Returns a `reuse(range, kind, lang)` lookup for walk(), which hands out each
+--- untouched node at most once, moved to its new range along with its subtree,
+--- and the set of nodes it has handed out.
+--- @param previous ScopeTree
+--- @param state table  _tracked entry
+--- @return fun(range: table, kind: string, lang: string): ScopeNode|nil, table<ScopeNode, boolean>
+local function reuser(previous, state)
+  local moved = {}
+  local candidates = {}
+
+  local function key(range, kind, lang)
+    return table.concat({ lang or "", kind, range.start_row, range.start_col, range.end_row, range.end_col }, ":")
+  end
+
+  -- Returns true if no node in the subtree is still deferred: a deferred node
+  -- holds a TSNode from the old parse and cannot be moved.
+  local function visit(node)
+    local complete = not node:is_deferred()
+    for _, child in ipairs(node.children) do
+      local range = child.range
+      for _, edit in ipairs(state.edits) do
+        range = range and apply_edit(range, edit)
+      end
+      for _, change in ipairs(state.changes) do
+        if range and overlaps(range, change) then
+          range = nil
+        end
+      end
+      local child_complete = visit(child)
+      complete = complete and child_complete
+      if range then
+        moved[child] = range
+        if _walked[child] and child_complete then
+          local k = key(range, _walked[child], child.lang)
+          candidates[k] = candidates[k] or {}
+          table.insert(candidates[k], child)
+        end
+      end
+    end
+    return complete
+  end
+  visit(previous.root)
+
+  local function move(node)
+    node.range = moved[node] or node.range
+    for _, child in ipairs(node.children) do
+      move(child)
+    end
+  end
+
+  local reused = {}
+  local function reuse(range, kind, lang)
+    local list = candidates[key(range, kind, lang)]
+    local node = list and table.remove(list, 1)
+    if node then
+      reused[node] = true
+      move(node)
+    end
+    return node
+  end
+  return reuse, reused
+end
+
+--- Return a stop hook for walk() that enforces the treesitter.max_lines,
+--- max_nodes and build_timeout_ms limits (0 disables one). Once a limit is
+--- hit the hook marks `scope_tree` truncated and keeps returning true.
+--- @param scope_tree ScopeTree
+--- @param limits scopes.TreesitterConfig
+--- @param elapsed_ms fun(): number  time spent walking so far
+--- @return fun(ts_node: TSNode): boolean
+local function limiter(scope_tree, limits, elapsed_ms)
+  local max_lines = limits.max_lines or 0
+  local max_nodes = limits.max_nodes or 0
+  local timeout_ms = limits.build_timeout_ms or 0
+  local visited = 0
+  return function(ts_node)
+    if scope_tree.truncated then
+      return true
+    end
+    visited = visited + 1
+    -- The clock is read every 100 nodes; on every node it would cost more than it saves.
+    local reason = nil
+    if max_nodes > 0 and visited > max_nodes then
+      reason = "max_nodes"
+    elseif max_lines > 0 and ts_node:start() >= max_lines then
+      reason = "max_lines"
+    elseif timeout_ms > 0 and visited % 100 == 0 and elapsed_ms() > timeout_ms then
+      reason = "build_timeout_ms"
+    end
+    if reason then
+      scope_tree.truncated = true
+      log.debug("build truncated buf=" .. scope_tree.bufnr .. " limit=" .. reason .. " nodes=" .. visited)
+      return true
+    end
+    return false
+  end
+end
+
+--- Resolve the parser and lang config for `bufnr`, parse it, and create a
+--- ScopeTree with an empty root. Returns the tree and a function that walks
+--- the parse tree into it, or nil when no tree can be built. `elapsed_ms`
+--- reports the time spent walking, for build_timeout_ms; it defaults to the
+--- time since fill() was called.
+--- @param bufnr number
+--- @param lang_config? LangConfig
+--- @param opts {previous?: ScopeTree, silent?: boolean}
+--- @return ScopeTree|nil, fun(tick?: fun(), elapsed_ms?: fun(): number)|nil
+local function start_build(bufnr, lang_config, opts)
   local cfg = require("scopes.config").get()
+  local explicit_config = lang_config
 
   -- Check for a filename-based parser/config override (e.g. BUILD files using the Python
   -- parser with the bzl lang config). This lets scopes parse files that have no Neovim
@@ -106,19 +710,32 @@ function M.build(bufnr)
   end
 
   if not ok or not parser then
-    vim.notify("scopes.nvim: no treesitter parser for buffer " .. bufnr, vim.log.levels.WARN)
+    if opts.silent then
+      log.debug("no treesitter parser for buffer " .. bufnr)
+    else
+      vim.notify("scopes.nvim: no treesitter parser for buffer " .. bufnr, vim.log.levels.WARN)
+    end
     return nil
   end
 
   local lang = forced_config or parser:lang()
 
-  local lang_config = lang_config_mod.load(lang)
+  lang_config = lang_config or lang_config_mod.load(lang, parser:lang())
+  if not lang_config then
+    log.debug("no language config for '" .. lang .. "', using generic heuristics")
+    lang_config = lang_config_mod.generic(parser:lang())
+  end
   if not lang_config then
-    -- TODO: implement generic fallback heuristics for unsupported languages
+    if opts.silent then
+      log.debug("no language config for '" .. lang .. "'")
+    else
+      vim.notify("scopes.nvim: no language config for '" .. lang .. "'", vim.log.levels.WARN)
+    end
     return nil
   end
 
-  local parse_ok, trees = pcall(parser.parse, parser)
+  -- parse(true) also parses injected languages; parse() only the host language.
+  local parse_ok, trees = pcall(parser.parse, parser, cfg.treesitter.injections or nil)
   if not parse_ok or not trees or not trees[1] then
     vim.notify("scopes.nvim: treesitter parse failed for buffer " .. bufnr, vim.log.levels.WARN)
     return nil
@@ -126,6 +743,18 @@ function M.build(bufnr)
 
   local ts_root = trees[1]:root()
 
+  -- While the buffer does not parse, keep serving the last error-free tree.
+  -- Its nodes may still be deferred in lazy mode, holding TSNodes that no
+  -- longer match the text, so lazy builds never keep one.
+  local keep_last_good = cfg.treesitter.keep_last_good and not cfg.treesitter.lazy
+  if keep_last_good and ts_root:has_error() then
+    local stale = last_good(bufnr, lang, explicit_config)
+    if stale then
+      log.debug("parse has errors, serving last good tree buf=" .. bufnr)
+      return stale, function() end
+    end
+  end
+
   -- Get buffer name for root node display
   local buf_name = vim.api.nvim_buf_get_name(bufnr)
   local file_name = vim.fn.fnamemodify(buf_name, ":t")
@@ -137,16 +766,204 @@ function M.build(bufnr)
     name = file_name,
     kind = "file",
     range = get_range(ts_root),
+    lang = lang,
   })
-
-  walk(ts_root, root, lang_config, bufnr)
-
-  return ScopeTree.new({
+  local scope_tree = ScopeTree.new({
     root = root,
     source = "treesitter",
     bufnr = bufnr,
     lang = lang,
   })
+
+  local function fill(tick, elapsed_ms)
+    if not elapsed_ms then
+      local started = vim.uv.hrtime()
+      elapsed_ms = function()
+        return (vim.uv.hrtime() - started) / 1e6
+      end
+    end
+    local hooks = { tick = tick, stop = limiter(scope_tree, cfg.treesitter, elapsed_ms) }
+    local reused = nil
+    local state = _tracked[bufnr]
+    local previous = opts.previous
+    if
+      cfg.treesitter.incremental
+      and previous
+      and state
+      and state.tree == previous
+      and state.parser == parser
+      and previous.lang == lang
+      and state.lang_config == explicit_config
+      and not state.reloaded
+    then
+      hooks.reuse, reused = reuser(previous, state)
+    end
+
+    if lang_config.prepare then
+      lang_config.prepare(ts_root, bufnr)
+    end
+    if cfg.treesitter.lazy then
+      hooks.lazy = true
+      hooks.injections = cfg.treesitter.injections and collect_injections(parser, bufnr) or nil
+      walk(ts_root, root, lang_config, bufnr, lang, hooks)
+      attach_lazy_injections(root, hooks.injections, bufnr)
+    else
+      walk(ts_root, root, lang_config, bufnr, lang, hooks)
+      if cfg.treesitter.injections then
+        attach_injections(parser, root, bufnr, hooks, reused)
+      end
+    end
+
+    report_name_failures()
+
+    -- A truncated tree is partial: not worth an extmark per node, nor serving later.
+    if keep_last_good and not ts_root:has_error() and not scope_tree.truncated then
+      remember(bufnr, scope_tree, explicit_config)
+    end
+
+    if cfg.treesitter.incremental then
+      track(bufnr, scope_tree, parser, explicit_config)
+    end
+  end
+
+  return scope_tree, fill
+end
+
+--- Return the name_getter failures recorded this session, per language and
+--- node type: `{ [lang] = { [node_type] = { count, err } } }`.
+--- @return table<string, table<string, {count: number, err: string}>>
+function M.name_failures()
+  local result = {}
+  for lang, by_type in pairs(_name_failures) do
+    result[lang] = {}
+    for node_type, failure in pairs(by_type) do
+      result[lang][node_type] = { count = failure.count, err = failure.err }
+    end
+  end
+  return result
+end
+
+--- Forget the recorded name_getter failures; they are reported again if they recur.
+function M.reset_name_failures()
+  _name_failures = {}
+end
+
+--- Build a ScopeTree from a buffer's Treesitter parse tree.
+--- Uses `lang_config` when given, otherwise the language's file in languages/,
+--- otherwise heuristics derived from the grammar (lang_config.generic()).
+---
+--- With `opts.previous` (the last tree built for this buffer) and
+--- treesitter.incremental enabled, nodes that no edit or Treesitter changed
+--- range touched since that build are reused as the same objects, with their
+--- ranges moved; only the subtrees around the changes are walked again.
+---
+--- When a treesitter.max_lines, max_nodes or build_timeout_ms limit is hit the
+--- walk stops and the partial tree is returned with `truncated = true`.
+---
+--- With treesitter.keep_last_good, a parse with syntax errors returns the last
+--- tree built from an error-free parse instead, ranges moved along with the
+--- text and flagged `stale = true`.
+---
+--- With `silent`, a buffer without a parser or lang config is only logged:
+--- for callers with a fallback of their own, like the "auto" backend.
+--- @param bufnr number
+--- @param lang_config? LangConfig
+--- @param opts? {previous?: ScopeTree, silent?: boolean}
+--- @return ScopeTree|nil
+function M.build(bufnr, lang_config, opts)
+  local scope_tree, fill = start_build(bufnr, lang_config, opts or {})
+  if not scope_tree then
+    return nil
+  end
+  fill()
+  return scope_tree
+end
+
+--- @class scopes.AsyncBuild
+--- @field tree ScopeTree  Filled in place as the build progresses.
+--- @field done boolean
+--- @field cancelled boolean
+--- @field cancel fun()
+
+--- Build a ScopeTree without blocking the main loop.
+--- The walk runs in a coroutine that yields every `chunk_size` syntax nodes
+--- and resumes from vim.schedule, so the returned tree fills in over several
+--- ticks. The build cancels itself when the buffer changes before it is done.
+--- Takes the same arguments as build(); returns nil when no tree can be built.
+--- @param bufnr number
+--- @param lang_config? LangConfig
+--- @param opts? {previous?: ScopeTree, silent?: boolean, chunk_size?: number, on_progress?: fun(tree: ScopeTree), on_done?: fun(tree: ScopeTree), on_cancel?: fun()}
+--- @return scopes.AsyncBuild|nil
+function M.build_async(bufnr, lang_config, opts)
+  opts = opts or {}
+  local scope_tree, fill = start_build(bufnr, lang_config, opts)
+  if not scope_tree then
+    return nil
+  end
+
+  local chunk_size = opts.chunk_size or require("scopes.config").get().async.chunk_size
+  local changedtick = vim.api.nvim_buf_get_changedtick(bufnr)
+  local visited = 0
+  -- Only time spent walking counts towards build_timeout_ms, not time spent
+  -- waiting for the next scheduled step.
+  local spent_ns, resumed_at = 0, 0
+  local co = coroutine.create(function()
+    fill(function()
+      visited = visited + 1
+      if visited % chunk_size == 0 then
+        coroutine.yield()
+      end
+    end, function()
+      return (spent_ns + vim.uv.hrtime() - resumed_at) / 1e6
+    end)
+  end)
+
+  --- @type scopes.AsyncBuild
+  local handle = { tree = scope_tree, done = false, cancelled = false }
+
+  function handle.cancel()
+    if handle.done or handle.cancelled then
+      return
+    end
+    handle.cancelled = true
+    log.debug("async build cancelled buf=" .. bufnr)
+    if opts.on_cancel then
+      opts.on_cancel()
+    end
+  end
+
+  local function step()
+    if handle.cancelled then
+      return
+    end
+    -- The TSNodes being walked belong to the old text once the buffer changes.
+    if not vim.api.nvim_buf_is_valid(bufnr) or vim.api.nvim_buf_get_changedtick(bufnr) ~= changedtick then
+      handle.cancel()
+      return
+    end
+    resumed_at = vim.uv.hrtime()
+    local ok, err = coroutine.resume(co)
+    spent_ns = spent_ns + vim.uv.hrtime() - resumed_at
+    if not ok then
+      vim.notify("scopes.nvim: async build failed for buffer " .. bufnr .. ": " .. tostring(err), vim.log.levels.WARN)
+      handle.cancel()
+      return
+    end
+    if coroutine.status(co) == "dead" then
+      handle.done = true
+      if opts.on_done then
+        opts.on_done(scope_tree)
+      end
+    else
+      if opts.on_progress then
+        opts.on_progress(scope_tree)
+      end
+      vim.schedule(step)
+    end
+  end
+
+  vim.schedule(step)
+  return handle
 end
 
 return M
@@ -0,0 +1,278 @@
+--- Breadcrumb component for scopes.nvim
+--- The scope path at the cursor ("sample.go > MyStruct > HandleRequest") for
+--- statuslines (lualine, heirline, ...) and the built-in winbar. Paths are
+--- cached per buffer on b:changedtick and the cursor row, so evaluating the
+--- component on every redraw is a table lookup. Trees are never built while a
+--- status line is drawn: an out-of-date buffer keeps its last path until a
+--- rebuild, debounced by cache.debounce_ms, finishes and redraws the status
+--- lines. In the winbar each segment is clickable and opens the picker at
+--- that level.
+
+local config = require("scopes.config")
+local Navigator = require("scopes.navigator")
+local tree_mod = require("scopes.tree")
+
+local M = {}
+
+local GROUP = "scopes_breadcrumb"
+local CACHE_GROUP = "scopes_breadcrumb_cache"
+
+--- Name of the global click handler used in %@...@ items, which can only
+--- call global functions.
+local CLICK_FN = "__scopes_breadcrumb_click"
+
+-- Trees per buffer: { [bufnr] = { tick: number|nil, tree: ScopeTree|nil, pending: number|nil, timer: uv_timer_t|nil } }
+-- `tick` is the changedtick `tree` was built at, `pending` the one a scheduled rebuild is for.
+local _trees = {}
+-- Breadcrumbs per buffer: { [bufnr] = { tick: number, row: number, nodes: ScopeNode[] } }
+local _paths = {}
+
+--- Build the tree for `bufnr` outside of status line evaluation. A large
+--- buffer is built in the background; its partial tree is used meanwhile.
+--- The status lines are redrawn as the tree changes.
+--- @param bufnr number
+--- @param entry table  the buffer's entry in _trees
+local function rebuild(bufnr, entry)
+  if _trees[bufnr] ~= entry or not vim.api.nvim_buf_is_valid(bufnr) then
+    return
+  end
+  local tick = vim.api.nvim_buf_get_changedtick(bufnr)
+  local function show(result)
+    entry.tree = result
+    _paths[bufnr] = nil
+    vim.cmd("redrawstatus!")
+  end
+  local done = false
+  local partial = tree_mod.build_async(bufnr, {
+    on_done = function(result)
+      done = true
+      if _trees[bufnr] ~= entry then
+        return
+      end
+      entry.tick = tick
+      show(result)
+    end,
+  })
+  if not done then
+    show(partial)
+  end
+end
+
+--- Stop and release the rebuild timer of a _trees entry, if any.
+--- @param entry table
+local function cancel(entry)
+  local timer = entry.timer
+  if timer then
+    entry.timer = nil
+    timer:stop()
+    timer:close()
+  end
+end
+
+--- Return the last tree built for `bufnr`, which may be out of date or nil.
+--- When it is, a rebuild is scheduled once cache.debounce_ms pass without
+--- another edit (right away while there is no tree yet); nothing is built
+--- here, so this is safe to call while a status line is drawn.
+--- @param bufnr number
+--- @return ScopeTree|nil
+local function tree_for(bufnr)
+  local tick = vim.api.nvim_buf_get_changedtick(bufnr)
+  local entry = _trees[bufnr]
+  if not entry then
+    entry = {}
+    _trees[bufnr] = entry
+  end
+  if entry.tick ~= tick and entry.pending ~= tick then
+    entry.pending = tick
+    if not entry.timer then
+      entry.timer = vim.uv.new_timer()
+    end
+    entry.timer:stop()
+    entry.timer:start(
+      entry.tree and config.get().cache.debounce_ms or 0,
+      0,
+      vim.schedule_wrap(function()
+        cancel(entry)
+        rebuild(bufnr, entry)
+      end)
+    )
+  end
+  return entry.tree
+end
+
+--- Resolve the buffer and window a breadcrumb is for.
+--- @param opts? {bufnr?: number, winid?: number}
+--- @return number|nil bufnr, number|nil winid
+local function resolve(opts)
+  opts = opts or {}
+  local winid = opts.winid
+  if not winid then
+    if opts.bufnr and opts.bufnr ~= vim.api.nvim_get_current_buf() then
+      winid = vim.fn.bufwinid(opts.bufnr)
+    else
+      winid = vim.api.nvim_get_current_win()
+    end
+  end
+  if not winid or winid == -1 or not vim.api.nvim_win_is_valid(winid) then
+    return nil, nil
+  end
+  local bufnr = opts.bufnr or vim.api.nvim_win_get_buf(winid)
+  if not vim.api.nvim_buf_is_valid(bufnr) or vim.api.nvim_win_get_buf(winid) ~= bufnr then
+    return nil, nil
+  end
+  return bufnr, winid
+end
+
+--- Return the scopes around the cursor, from the file root down to the
+--- deepest scope containing the cursor row. Empty when the buffer has no tree.
+--- @param opts? {bufnr?: number, winid?: number}  defaults to the current window and its buffer
+--- @return ScopeNode[]
+function M.nodes(opts)
+  local bufnr, winid = resolve(opts)
+  if not bufnr then
+    return {}
+  end
+  local tick = vim.api.nvim_buf_get_changedtick(bufnr)
+  local row = vim.api.nvim_win_get_cursor(winid)[1] - 1
+  local cached = _paths[bufnr]
+  if cached and cached.tick == tick and cached.row == row then
+    return cached.nodes
+  end
+
+  local scope_tree = tree_for(bufnr)
+  local nodes = {}
+  if scope_tree then
+    nodes = Navigator.new(scope_tree, { cursor_row = row }):breadcrumb()
+  end
+  _paths[bufnr] = { tick = tick, row = row, nodes = nodes }
+  return nodes
+end
+
+--- Return the scope path at the cursor as plain text, e.g. for a statusline
+--- component. Empty when the buffer has no tree.
+--- @param opts? {bufnr?: number, winid?: number}  defaults to the current window and its buffer
+--- @return string
+function M.get(opts)
+  local names = vim.tbl_map(function(node)
+    return node.name
+  end, M.nodes(opts))
+  return table.concat(names, config.get().breadcrumb.separator)
+end
+
+--- Escape text for use in a statusline.
+--- @param text string
+--- @return string
+local function escape(text)
+  return (text:gsub("%%", "%%%%"))
+end
+
+--- Render the breadcrumb for the window being drawn, with one click region
+--- per segment. For 'winbar' or 'statusline' as `%{%v:lua.require'scopes.breadcrumb'.winbar()%}`.
+--- @return string
+function M.winbar()
+  local winid = vim.g.statusline_winid or vim.api.nvim_get_current_win()
+  local segments = {}
+  for depth, node in ipairs(M.nodes({ winid = winid })) do
+    table.insert(segments, "%" .. depth .. "@v:lua." .. CLICK_FN .. "@" .. escape(node.name) .. "%X")
+  end
+  return table.concat(segments, escape(config.get().breadcrumb.separator))
+end
+
+--- Click handler for a winbar segment: open the picker at that level of the
+--- clicked window's breadcrumb.
+--- @param depth number  the segment's position, 1 = file root
+function M.click(depth)
+  local winid = vim.fn.getmousepos().winid
+  if winid == 0 or not vim.api.nvim_win_is_valid(winid) then
+    return
+  end
+  vim.api.nvim_set_current_win(winid)
+  require("scopes").open({ depth = depth })
+end
+
+--- Install the click handler and the autocmds that drop cached trees
+--- rebuilt for reasons b:changedtick does not show (see tree.invalidate).
+--- Called by require("scopes").setup() and enable_winbar(); safe to call again.
+function M.setup()
+  _G[CLICK_FN] = function(minwid)
+    M.click(minwid)
+  end
+  local group = vim.api.nvim_create_augroup(CACHE_GROUP, { clear = true })
+  vim.api.nvim_create_autocmd("User", {
+    group = group,
+    pattern = "ScopesInvalidated",
+    callback = function(ev)
+      M.clear(ev.data and ev.data.buf)
+    end,
+  })
+  vim.api.nvim_create_autocmd("BufWipeout", {
+    group = group,
+    callback = function(ev)
+      M.clear(ev.buf)
+    end,
+  })
+end
+
+--- Returns true if `win` gets the breadcrumb winbar: a normal file window.
+--- @param win number
+--- @return boolean
+local function wants_winbar(win)
+  if vim.api.nvim_win_get_config(win).relative ~= "" then
+    return false
+  end
+  local bufnr = vim.api.nvim_win_get_buf(win)
+  return vim.api.nvim_get_option_value("buftype", { buf = bufnr }) == ""
+end
+
+local WINBAR = "%{%v:lua.require'scopes.breadcrumb'.winbar()%}"
+
+--- Show the breadcrumb in the winbar of file windows, now and as they open.
+--- Windows with a winbar of their own are left alone.
+function M.enable_winbar()
+  local function apply(win)
+    if vim.api.nvim_win_is_valid(win) and wants_winbar(win) and vim.wo[win].winbar == "" then
+      vim.api.nvim_set_option_value("winbar", WINBAR, { scope = "local", win = win })
+    end
+  end
+  M.setup()
+  local group = vim.api.nvim_create_augroup(GROUP, { clear = true })
+  vim.api.nvim_create_autocmd({ "BufWinEnter", "WinNew" }, {
+    group = group,
+    callback = function()
+      apply(vim.api.nvim_get_current_win())
+    end,
+  })
+  for _, win in ipairs(vim.api.nvim_list_wins()) do
+    apply(win)
+  end
+end
+
+--- Remove the breadcrumb winbar set by enable_winbar().
+function M.disable_winbar()
+  pcall(vim.api.nvim_del_augroup_by_name, GROUP)
+  for _, win in ipairs(vim.api.nvim_list_wins()) do
+    if vim.wo[win].winbar == WINBAR then
+      vim.api.nvim_set_option_value("winbar", "", { scope = "local", win = win })
+    end
+  end
+end
+
+--- Forget cached trees and paths for `bufnr`, or for every buffer.
+--- @param bufnr? number
+function M.clear(bufnr)
+  if bufnr then
+    if _trees[bufnr] then
+      cancel(_trees[bufnr])
+    end
+    _trees[bufnr] = nil
+    _paths[bufnr] = nil
+  else
+    for _, entry in pairs(_trees) do
+      cancel(entry)
+    end
+    _trees = {}
+    _paths = {}
+  end
+end
+
+return M
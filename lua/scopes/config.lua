@@ -1,11 +1,16 @@
 --- @class scopes.Config
---- @field backend "treesitter"|"lsp"|"auto"
+--- @field backend "treesitter"|"lsp"|"auto"|"indent"
 --- @field debug boolean
 --- @field keymaps scopes.KeymapConfig
 --- @field picker scopes.PickerConfig
 --- @field display scopes.DisplayConfig
+--- @field sidebar scopes.SidebarConfig
+--- @field breadcrumb scopes.BreadcrumbConfig
 --- @field treesitter scopes.TreesitterConfig
 --- @field cache scopes.CacheConfig
+--- @field lsp scopes.LspConfig
+--- @field async scopes.AsyncConfig
+--- @field prebuild scopes.PrebuildConfig
 --- @field filename_parsers table<string, string|{parser: string, config: string}>  Maps buffer basename to a treesitter parser override. Value is either a parser language string, or a table with `parser` (treesitter lang) and `config` (lang config name) to decouple them. Does not change the buffer filetype — no LSP or diagnostics side effects.
 
 --- @class scopes.KeymapConfig
@@ -19,10 +24,13 @@
 --- @field close string[]
 --- @field split_vertical string
 --- @field split_horizontal string
---- @field backend "snacks"|"telescope"
---- @field preview boolean
---- @field width? number
---- @field height? number
+--- @field levels string  List the breadcrumb's levels to go up several at once; again to return.
+--- @field recursive string  List every descendant of the current scope, matched on its path; again to return.
+--- @field nav_entries boolean  List `..` (go up) and `.` (jump to the scope itself) above a scope's items.
+--- @field backend "snacks"|"telescope"|"fzf-lua"|"mini.pick"|"native"|string  A missing picker falls back to "native"; other names come from picker.register().
+--- @field preview boolean  Peek at the highlighted item in the main window (and the picker's own preview pane, if it has one).
+--- @field width? number  Fraction of the editor (<= 1) or an absolute size.
+--- @field height? number  Fraction of the editor (<= 1) or an absolute size.
 --- @field border? string
 
 --- @class scopes.DisplayConfig
@@ -30,12 +38,40 @@
 --- @field line_numbers boolean
 --- @field breadcrumb boolean
 
+--- @class scopes.SidebarConfig
+--- @field width number  Width in columns of the :ScopeToggleSidebar split.
+--- @field position "left"|"right"
+
+--- @class scopes.BreadcrumbConfig
+--- @field winbar boolean  Show the scope path at the cursor in the winbar of file windows.
+--- @field separator string
+
 --- @class scopes.TreesitterConfig
 --- @field scope_types table<string, string[]>
+--- @field injections boolean  Attach injected-language regions (code fences, embedded SQL, ...) under their host scope.
+--- @field incremental boolean  Rebuild only the parts of the tree touched by edits, reusing unchanged ScopeNodes.
+--- @field lazy boolean  Build a node's children only when they are first needed (drill-down, cursor lookup).
+--- @field keep_last_good boolean  While the buffer has syntax errors, serve the last error-free tree (ignored with lazy).
+--- @field max_lines number  Stop the walk at syntax nodes starting on or after this line (0 = no limit).
+--- @field max_nodes number  Stop the walk after this many syntax nodes (0 = no limit).
+--- @field build_timeout_ms number  Stop the walk after this much time spent walking (0 = no limit).
 
 --- @class scopes.CacheConfig
 --- @field enabled boolean
---- @field debounce_ms number
+--- @field debounce_ms number  Quiet time after the last edit before a rebuild (prebuild.enabled, sidebar, breadcrumb).
+--- @field max_entries number  Most trees held at once (across buffers); least recently used are evicted first.
+
+--- @class scopes.LspConfig
+--- @field timeout_ms number  Max time to block waiting for a documentSymbol response.
+--- @field merge_timeout_ms number  Max time the "auto" backend waits for LSP data before using Treesitter alone. The wait blocks, once per uncached build.
+
+--- @class scopes.AsyncConfig
+--- @field enabled boolean  Build large buffers in chunks instead of blocking the UI.
+--- @field min_lines number  Buffers shorter than this are always built synchronously.
+--- @field chunk_size number  Syntax nodes walked per scheduled step.
+
+--- @class scopes.PrebuildConfig
+--- @field enabled boolean  Build and cache trees in the background on BufEnter, BufWritePost, CursorHold and after edits.
 
 local M = {}
 
@@ -54,6 +90,9 @@ M.defaults = {
     close = { "<Esc>", "q" },
     split_vertical = "<C-v>",
     split_horizontal = "<C-s>",
+    levels = "<C-b>",
+    recursive = "<C-r>",
+    nav_entries = false,
     backend = "snacks",
     preview = true,
     width = nil,
@@ -65,13 +104,43 @@ M.defaults = {
     line_numbers = true, -- TODO: Not yet used
     breadcrumb = true, -- TODO: Not yet used
   },
-  -- TODO: Not yet used
+  sidebar = {
+    width = 35,
+    position = "right",
+  },
+  breadcrumb = {
+    winbar = false,
+    separator = " > ",
+  },
   treesitter = {
-    scope_types = {},
+    scope_types = {}, -- TODO: Not yet used
+    injections = true,
+    incremental = true,
+    lazy = false,
+    keep_last_good = false,
+    -- Safeguards for huge or generated files; a build that hits one returns a
+    -- partial tree with `truncated = true`.
+    max_lines = 30000,
+    max_nodes = 200000,
+    build_timeout_ms = 2000,
   },
   cache = {
     enabled = true,
     debounce_ms = 300,
+    max_entries = 20,
+  },
+  lsp = {
+    timeout_ms = 1000,
+    -- Kept short: "auto" already has a Treesitter tree and only waits for extra detail.
+    merge_timeout_ms = 200,
+  },
+  prebuild = {
+    enabled = false,
+  },
+  async = {
+    enabled = true,
+    min_lines = 5000,
+    chunk_size = 2000,
   },
   -- Maps buffer basename to parser/config overrides for files Neovim doesn't assign a
   -- filetype to. Scopes uses the specified parser and lang config internally without
@@ -0,0 +1,416 @@
+--- Picker controller for scopes.nvim
+--- Owns everything a picker does with the Navigator (drill-down, go-up,
+--- jumping, restoring the cursor on cancel) so that adapters only draw.
+---
+--- An adapter is `{ available?: fun(): boolean, open: fun(ctrl: scopes.Controller): scopes.PickerView|nil }`.
+--- open() shows `ctrl:items()` under `ctrl:title()` and reports user input
+--- back through ctrl:drill(), ctrl:up(), ctrl:confirm() and ctrl:closed().
+--- Adapters that can tell when the highlighted item changes also call
+--- ctrl:preview() to peek at it in the main window, and bind picker.levels
+--- to ctrl:toggle_levels() and picker.recursive to ctrl:toggle_recursive().
+--- Adapters filter on ctrl:label(node), which is what parts() shows as the name.
+
+local config = require("scopes.config")
+local icons = require("scopes.icons")
+
+local PEEK_NS = vim.api.nvim_create_namespace("scopes_peek")
+
+--- @class scopes.PickerView
+--- @field show fun(self: scopes.PickerView, items: ScopeNode[])  Replace the listed items.
+--- @field set_title fun(self: scopes.PickerView, title: string)
+--- @field refresh fun(self: scopes.PickerView)  Re-read the items last shown; the list may have grown in place.
+--- @field focus fun(self: scopes.PickerView, index: number)  Select items[index] of the items last shown.
+--- @field close fun(self: scopes.PickerView)
+
+--- @class scopes.Controller
+--- @field nav Navigator
+--- @field bufnr number
+--- @field main_win number
+--- @field view scopes.PickerView|nil
+--- @field _original_view table  winsaveview() of main_win when the picker opened.
+--- @field _confirmed boolean
+--- @field _closed boolean
+--- @field _mode "levels"|"recursive"|nil  Listing the breadcrumb (toggle_levels()) or every
+--- descendant (toggle_recursive()) instead of the current level.
+local Controller = {}
+Controller.__index = Controller
+
+--- Create a controller for `nav`, remembering the current window and cursor.
+--- @param nav Navigator
+--- @param bufnr number
+--- @return scopes.Controller
+function Controller.new(nav, bufnr)
+  local self = setmetatable({}, Controller)
+  self.nav = nav
+  self.bufnr = bufnr
+  self.main_win = vim.api.nvim_get_current_win()
+  self.view = nil
+  self._original_view = vim.api.nvim_win_call(self.main_win, vim.fn.winsaveview)
+  self._confirmed = false
+  self._closed = false
+  self._mode = nil
+  return self
+end
+
+--- Return every descendant of `node`, depth first, building lazy children.
+--- @param node ScopeNode
+--- @param out? ScopeNode[]
+--- @return ScopeNode[]
+local function descendants(node, out)
+  out = out or {}
+  for _, child in ipairs(node:expand()) do
+    table.insert(out, child)
+    descendants(child, out)
+  end
+  return out
+end
+
+--- Return the items to show: the children of the navigator's current node,
+--- its breadcrumb while the levels are listed, or all its descendants while
+--- listing recursively.
+--- @return ScopeNode[]
+function Controller:items()
+  if self._mode == "levels" then
+    return self.nav:breadcrumb()
+  elseif self._mode == "recursive" then
+    return descendants(self.nav:current())
+  end
+  return self.nav:items()
+end
+
+--- Return the text a node is shown and filtered by: its name or, while
+--- listing recursively, its path below the current node ("MyStruct > HandleRequest > for").
+--- @param node ScopeNode
+--- @return string
+function Controller:label(node)
+  if self._mode ~= "recursive" then
+    return node.name
+  end
+  local current = self.nav:current()
+  local names = {}
+  local ancestor = node
+  while ancestor and ancestor ~= current do
+    table.insert(names, 1, ancestor.name)
+    ancestor = ancestor.parent
+  end
+  return table.concat(names, " > ")
+end
+
+--- Picker title: the breadcrumb, flagged when a build limit cut the tree
+--- short and while the levels or all descendants are listed.
+--- @return string
+function Controller:title()
+  local title = self.nav:breadcrumb_string()
+  if self.nav:tree().truncated then
+    title = title .. " [truncated]"
+  end
+  if self._mode then
+    title = title .. " [" .. self._mode .. "]"
+  end
+  return title
+end
+
+--- Split a node's display into labelled pieces, for adapters to join and
+--- highlight in their own style. Roles: "icon", "name", "error" (the name of
+--- an ERROR node), "kind", "line" and "drill" (marks a scope).
+--- @param node ScopeNode
+--- @return {text: string, role: string}[]
+function Controller:parts(node)
+  local parts = {}
+  if config.get().display.icons then
+    table.insert(parts, { text = icons.get_icon(node.kind), role = "icon" })
+  end
+  table.insert(parts, { text = self:label(node), role = node.is_error and "error" or "name" })
+  table.insert(parts, { text = "[" .. node.kind .. "]", role = "kind" })
+  table.insert(parts, { text = ":" .. (node.range.start_row + 1), role = "line" })
+  if node:is_scope() then
+    table.insert(parts, { text = "", role = "drill" })
+  end
+  return parts
+end
+
+--- Return the index of `node` in `items`, if listed.
+--- @param items ScopeNode[]
+--- @param node ScopeNode
+--- @return number|nil
+local function index_of(items, node)
+  for i, item in ipairs(items) do
+    if item == node then
+      return i
+    end
+  end
+  return nil
+end
+
+--- Show the navigator's current level in the view, selecting `focus` if given.
+--- @param focus? ScopeNode
+function Controller:_show(focus)
+  if not self.view or self._closed then
+    return
+  end
+  local items = self:items()
+  self.view:set_title(self:title())
+  self.view:show(items)
+  local index = focus and index_of(items, focus)
+  if index then
+    self.view:focus(index)
+  end
+end
+
+--- Show the level of `node`, one of the listed levels, selecting the scope
+--- below it on the way to the level the levels were listed from.
+--- @param node ScopeNode
+function Controller:_open_level(node)
+  local path = self.nav:breadcrumb()
+  local below = nil
+  for i, level in ipairs(path) do
+    if level == node then
+      below = path[i + 1]
+      break
+    end
+  end
+  self._mode = nil
+  self.nav:open_at(node)
+  self:_show(below)
+end
+
+--- Show the level `node` is listed at, selecting it: for a node found by
+--- listing recursively.
+--- @param node ScopeNode
+function Controller:_open_parent_level(node)
+  self._mode = nil
+  self.nav:open_at(node.parent or self.nav:tree().root)
+  self:_show(node)
+end
+
+--- List the breadcrumb's levels, from the file root down to the current
+--- scope, to go up several levels at once; the current scope is selected.
+--- Called again, return to the current level.
+function Controller:toggle_levels()
+  if self._mode == "levels" then
+    self._mode = nil
+    self:_show(nil)
+    return
+  end
+  self._mode = "levels"
+  self:_show(self.nav:current())
+end
+
+--- List every descendant of the current scope, each labelled with its path
+--- below it, to find a symbol without knowing where it is nested.
+--- Called again, return to the current level.
+function Controller:toggle_recursive()
+  if self._mode == "recursive" then
+    self._mode = nil
+    self:_show(nil)
+    return
+  end
+  self._mode = "recursive"
+  self:_show(nil)
+end
+
+--- Returns true if confirming `node` moves within the picker rather than
+--- jumping: a listed level, or a `..` entry. Adapters whose confirm always
+--- closes the picker check this first.
+--- @param node? ScopeNode
+--- @return boolean
+function Controller:navigates(node)
+  return node ~= nil and (self._mode == "levels" or node.synthetic == "parent")
+end
+
+--- Drill into `node`. Returns false for a leaf. Drilling into a listed
+--- level shows it, into a `..` entry goes up, and into a node listed
+--- recursively shows the level it is at.
+--- @param node ScopeNode
+--- @return boolean
+function Controller:drill(node)
+  if not node then
+    return false
+  end
+  if self._mode == "levels" then
+    self:_open_level(node)
+    return true
+  elseif self._mode == "recursive" then
+    self:_open_parent_level(node)
+    return true
+  end
+  if node.synthetic == "parent" then
+    return self:up()
+  end
+  if not self.nav:drill_down(node) then
+    return false
+  end
+  self:_show(nil)
+  return true
+end
+
+--- Go up to the parent scope, selecting the scope just left. While the
+--- levels or all descendants are listed, return to the current level instead.
+--- @return boolean
+function Controller:up()
+  if self._mode then
+    self._mode = nil
+    self:_show(nil)
+    return true
+  end
+  local prev_node = self.nav:current()
+  if not self.nav:go_up() then
+    return false
+  end
+  self:_show(prev_node)
+  return true
+end
+
+--- Jump to a range in the given window, optionally opening a split first.
+--- @param range {row: number, col: number}
+--- @param split_mode "current"|"vsplit"|"hsplit"
+--- @param target_win number
+local function open_at(range, split_mode, target_win)
+  if not range then
+    return
+  end
+  if not vim.api.nvim_win_is_valid(target_win) then
+    return
+  end
+  -- Ensure focus is on target_win before splitting; no-op for "current" mode.
+  vim.api.nvim_set_current_win(target_win)
+  local dest_win
+  if split_mode == "vsplit" then
+    dest_win = vim.api.nvim_open_win(0, true, { split = "right" })
+  elseif split_mode == "hsplit" then
+    dest_win = vim.api.nvim_open_win(0, true, { split = "below" })
+  elseif split_mode == "current" then
+    dest_win = target_win
+  else
+    vim.notify("scopes.nvim: unknown split_mode: " .. tostring(split_mode), vim.log.levels.WARN)
+    return
+  end
+  vim.api.nvim_win_set_cursor(dest_win, { range.row + 1, range.col })
+end
+
+--- Peek at `node` in the main window, when picker.preview is on: highlight
+--- its range, move the cursor to its start and scroll so the whole range is
+--- visible, or its start is at the top when it is taller than the window.
+--- A nil node only clears the highlight. closed() undoes all of it.
+--- @param node? ScopeNode
+function Controller:preview(node)
+  if self._closed or not config.get().picker.preview or not vim.api.nvim_win_is_valid(self.main_win) then
+    return
+  end
+  vim.api.nvim_buf_clear_namespace(self.bufnr, PEEK_NS, 0, -1)
+  if not node or vim.api.nvim_win_get_buf(self.main_win) ~= self.bufnr then
+    return
+  end
+  local r = node.range
+  vim.api.nvim_buf_set_extmark(self.bufnr, PEEK_NS, r.start_row, r.start_col, {
+    end_row = r.end_row,
+    end_col = r.end_col,
+    hl_group = "Visual",
+    strict = false,
+  })
+  vim.api.nvim_win_call(self.main_win, function()
+    local height = vim.api.nvim_win_get_height(0)
+    local size = r.end_row - r.start_row + 1
+    local topline = r.start_row + 1
+    if size < height then
+      topline = math.max(1, topline - math.floor((height - size) / 2))
+    end
+    vim.fn.winrestview({ topline = topline, lnum = r.start_row + 1, col = r.start_col })
+  end)
+end
+
+--- Close the picker and jump to `node`, in the window the picker was opened
+--- from or a new split of it. A `.` entry jumps to the current scope itself.
+--- While the levels are listed, show the level of `node` instead, and for a
+--- `..` entry go up (see navigates()).
+--- @param node ScopeNode
+--- @param split_mode? "current"|"vsplit"|"hsplit"  defaults to "current"
+function Controller:confirm(node, split_mode)
+  if not node then
+    return
+  end
+  if self:navigates(node) then
+    self:drill(node)
+    return
+  end
+  self._confirmed = true
+  local pos = self.nav:enter(node)
+  if self.view and not self._closed then
+    self.view:close()
+  end
+  self:closed()
+  open_at(pos, split_mode or "current", self.main_win)
+end
+
+--- Record that the picker has closed; adapters call this however it closed.
+--- Clears the peek highlight and, without a confirmed jump, puts the cursor
+--- and scroll position back where they were.
+function Controller:closed()
+  if self._closed then
+    return
+  end
+  self._closed = true
+  if vim.api.nvim_buf_is_valid(self.bufnr) then
+    vim.api.nvim_buf_clear_namespace(self.bufnr, PEEK_NS, 0, -1)
+  end
+  if not self._confirmed and vim.api.nvim_win_is_valid(self.main_win) then
+    local view = self._original_view
+    vim.api.nvim_win_call(self.main_win, function()
+      vim.fn.winrestview(view)
+    end)
+  end
+end
+
+--- Returns true once the picker has closed.
+--- @return boolean
+function Controller:is_closed()
+  return self._closed
+end
+
+--- Re-read the navigator's items and breadcrumb, e.g. while an async build is
+--- still filling in the tree. With `opts.focus_cursor`, also select the item
+--- containing the cursor. While the levels, all descendants or the `..` and
+--- `.` entries are listed, the view holds a list computed by items(), so it
+--- is shown afresh.
+--- @param opts? {focus_cursor?: boolean}
+function Controller:refresh(opts)
+  if not self.view or self._closed then
+    return
+  end
+  if opts and opts.focus_cursor then
+    -- The navigator may have moved to another level: show it afresh.
+    self._mode = nil
+    self.view:set_title(self:title())
+    self.view:show(self:items())
+    local index = self.nav:cursor_index()
+    if index then
+      self.view:focus(index)
+    end
+    return
+  end
+  self.view:set_title(self:title())
+  if self._mode or self.nav:has_nav_entries() then
+    self.view:show(self:items())
+    return
+  end
+  self.view:refresh()
+end
+
+--- Open `adapter` for this controller and select the item under the cursor.
+--- Returns false when the adapter could not open.
+--- @param adapter {open: fun(ctrl: scopes.Controller): scopes.PickerView|nil}
+--- @return boolean
+function Controller:attach(adapter)
+  local view = adapter.open(self)
+  if not view then
+    return false
+  end
+  self.view = view
+  local index = self.nav:cursor_index()
+  if index and not self._closed then
+    view:focus(index)
+  end
+  return true
+end
+
+return Controller
@@ -8,6 +8,7 @@ local BUILTIN = {
   ["method"] = "󰊕",
   ["class"] = "󰆧",
   ["struct"] = "󰆧",
+  ["interface"] = "",
   ["variable"] = "󰀫",
   ["const"] = "󰏿",
   ["type"] = "󰊱",
@@ -24,6 +25,7 @@ local KIND_TO_LSP = {
   ["method"] = "Method",
   ["class"] = "Class",
   ["struct"] = "Class",
+  ["interface"] = "Interface",
   ["variable"] = "Variable",
   ["const"] = "Constant",
   ["type"] = "TypeParameter",
@@ -23,18 +23,28 @@ function M.setup(opts)
     end, { desc = "Scope: open at file root" })
   end
 
-  -- Invalidate cached tree when a buffer is edited or written.
+  -- Cached trees are keyed on b:changedtick, so edits need no autocmd. A
+  -- language server attaching changes what the "auto" and "lsp" backends
+  -- produce without changing the buffer.
   local tree = require("scopes.tree")
-  vim.api.nvim_create_autocmd({ "TextChanged", "TextChangedI", "BufWritePost" }, {
+  vim.api.nvim_create_autocmd("LspAttach", {
     group = vim.api.nvim_create_augroup("scopes_cache_invalidate", { clear = true }),
     callback = function(ev)
-      if vim.api.nvim_get_option_value("buftype", { buf = ev.buf }) ~= "" then
-        return
-      end
       log.debug("cache invalidated buf=" .. ev.buf .. " event=" .. ev.event)
       tree.invalidate(ev.buf)
     end,
   })
+  require("scopes.breadcrumb").setup()
+  if cfg.breadcrumb.winbar then
+    require("scopes.breadcrumb").enable_winbar()
+  else
+    require("scopes.breadcrumb").disable_winbar()
+  end
+  if cfg.prebuild.enabled then
+    require("scopes.prebuild").start()
+  else
+    require("scopes.prebuild").stop()
+  end
   -- Clean up cache entry when a buffer is removed from memory.
   vim.api.nvim_create_autocmd({ "BufUnload", "BufWipeout" }, {
     group = vim.api.nvim_create_augroup("scopes_cache_cleanup", { clear = true }),
@@ -43,28 +53,75 @@ function M.setup(opts)
         return
       end
       log.debug("cache cleaned up buf=" .. ev.buf .. " event=" .. ev.event)
-      tree.invalidate(ev.buf)
+      tree.clear(ev.buf)
     end,
   })
 end
 
 --- Open the scope picker.
---- @param opts? { root?: boolean }
+--- With `depth`, open at that level of the cursor's breadcrumb instead of the
+--- deepest scope (1 = file root), e.g. from a clicked winbar segment.
+--- @param opts? { root?: boolean, depth?: number }
 function M.open(opts)
   opts = opts or {}
   local bufnr = vim.api.nvim_get_current_buf()
-  local cursor_row = vim.api.nvim_win_get_cursor(0)[1] - 1 -- 0-indexed
+  local cursor = vim.api.nvim_win_get_cursor(0)
+  local cursor_row, cursor_col = cursor[1] - 1, cursor[2] -- 0-indexed
 
-  local scope_tree = require("scopes.tree").build(bufnr)
+  -- Large buffers build asynchronously: the picker opens on the partial tree
+  -- and refreshes as chunks arrive.
+  local nav, handle
+
+  --- Move the navigator to the cursor's scope, or its ancestor at opts.depth.
+  local function place()
+    nav:open_at_cursor(cursor_row, cursor_col)
+    while opts.depth and #nav:breadcrumb() > opts.depth and nav:go_up() do
+    end
+  end
+
+  local scope_tree = require("scopes.tree").build_async(bufnr, {
+    on_progress = function()
+      if handle then
+        handle:refresh()
+      end
+    end,
+    on_done = function(result)
+      if not handle or not result then
+        return
+      end
+      -- The cursor's scope may not have existed yet when the picker opened.
+      if not opts.root and nav:current() == result.root then
+        place()
+        handle:refresh({ focus_cursor = true })
+        return
+      end
+      handle:refresh()
+    end,
+  })
   if not scope_tree then
     vim.notify("scopes.nvim: could not build scope tree for this buffer", vim.log.levels.WARN)
     return
   end
 
-  local nav_opts = opts.root and {} or { cursor_row = cursor_row }
-  local nav = require("scopes.navigator").new(scope_tree, nav_opts)
+  nav = require("scopes.navigator").new(scope_tree, { nav_entries = config.get().picker.nav_entries })
+  if not opts.root then
+    place()
+  end
+
+  handle = require("scopes.picker").open(nav, bufnr)
+end
+
+--- Return the scope path at the cursor, e.g. "main.go > MyStruct > HandleRequest",
+--- for statusline components. Cached per b:changedtick and cursor row.
+--- @param opts? {bufnr?: number, winid?: number}  defaults to the current window and its buffer
+--- @return string
+function M.breadcrumb(opts)
+  return require("scopes.breadcrumb").get(opts)
+end
 
-  require("scopes.picker").open(nav, bufnr)
+--- Open the sidebar outline for the current buffer, or close it.
+function M.toggle_sidebar()
+  require("scopes.sidebar").toggle()
 end
 
 return M
@@ -1,5 +1,18 @@
 --- LangConfig builder for scopes.nvim.
---- Derives scope_types, symbol_types, kind_map, and get_name from a raw node_types table.
+--- Derives scope_types, symbol_types, kind_map, and get_name from a raw node_types table,
+--- or from a queries/<lang>/scopes.scm query file on the runtimepath.
+
+--- @class LangConfig
+--- @field node_types table<string, table>
+--- @field scope_types string[]
+--- @field symbol_types string[]
+--- @field kind_map table<string, string>
+--- @field get_name fun(node: TSNode, source: number): string
+--- @field classify? fun(node: TSNode): "scope"|"symbol"|nil  Per-node override of scope_types/symbol_types.
+--- @field is_generic? boolean  True for configs built by generic() rather than a languages/ file.
+--- @field get_kind? fun(node: TSNode): string|nil  Per-node override of kind_map.
+--- @field prepare? fun(root: TSNode, source: number)  Called once per build before the tree is walked.
+--- @field query? vim.treesitter.Query  The scopes.scm query a config was built from.
 
 local M = {}
 
@@ -31,17 +44,319 @@ function M.build(node_types)
   return config
 end
 
+--- Substring patterns used to guess a kind from a grammar's node type name.
+--- Checked in order; the first match wins.
+local GENERIC_KIND_PATTERNS = {
+  { "method", "method" },
+  { "function", "function" },
+  { "func", "function" },
+  { "lambda", "function" },
+  { "closure", "function" },
+  { "constructor", "method" },
+  { "class", "class" },
+  { "struct", "struct" },
+  { "interface", "interface" },
+  { "trait", "interface" },
+  { "protocol", "interface" },
+  { "enum", "type" },
+  { "type", "type" },
+  { "module", "module" },
+  { "namespace", "module" },
+  { "package", "module" },
+  { "impl", "module" },
+  { "const", "const" },
+}
+
+--- Node types that declare a name but are never worth listing on their own.
+local GENERIC_IGNORED_PATTERNS = { "parameter", "argument" }
+
+--- Node type suffixes/prefixes that mark a named, body-less node as a symbol.
+local GENERIC_SYMBOL_PATTERNS = {
+  "^declaration$",
+  "_declaration$",
+  "_definition$",
+  "_spec$",
+  "_item$",
+  "^field",
+  "_field$",
+}
+
+--- Guess a kind string from a node type name.
+--- @param node_type string
+--- @return string
+local function guess_kind(node_type)
+  for _, entry in ipairs(GENERIC_KIND_PATTERNS) do
+    if node_type:find(entry[1], 1, true) then
+      return entry[2]
+    end
+  end
+  if node_type:find("declarat") or node_type:find("assignment") or node_type:find("field") then
+    return "variable"
+  end
+  return "block"
+end
+
+--- Returns true if `node_type` matches any Lua pattern in `patterns`.
+--- @param node_type string
+--- @param patterns string[]
+--- @return boolean
+local function matches_any(node_type, patterns)
+  for _, pattern in ipairs(patterns) do
+    if node_type:find(pattern) then
+      return true
+    end
+  end
+  return false
+end
+
+--- Find the node that names `node`: its `name` field, or the innermost
+--- `declarator` for C-family grammars (`int *add(int a)` → `add`).
+--- @param node TSNode
+--- @return TSNode|nil
+local function generic_name_node(node)
+  local name_node = node:field("name")[1]
+  if name_node then
+    return name_node
+  end
+  local declarator = node:field("declarator")[1]
+  while declarator do
+    local inner = declarator:field("declarator")[1]
+    if not inner then
+      return declarator:type():find("identifier") and declarator or nil
+    end
+    declarator = inner
+  end
+  return nil
+end
+
+--- Returns true if `node` has a body: a `body` field, or a named child
+--- whose type looks like a block (`block`, `class_body`, ...).
+--- @param node TSNode
+--- @return boolean
+local function has_body(node)
+  if node:field("body")[1] then
+    return true
+  end
+  for child in node:iter_children() do
+    if child:named() then
+      local child_type = child:type()
+      if child_type:find("block") or child_type:find("body") then
+        return true
+      end
+    end
+  end
+  return false
+end
+
+--- Build a heuristic LangConfig for a language that has no file in languages/.
+--- Uses the grammar itself: any node with a name (a `name` field or a C-style
+--- `declarator` chain) and a body is a scope; a named declaration without a
+--- body is a symbol. Returns nil when the parser is not installed or the
+--- grammar has no `name` or `declarator` fields to work with.
+--- @param lang string  Treesitter language name
+--- @return LangConfig|nil
+function M.generic(lang)
+  local ok, info = pcall(vim.treesitter.language.inspect, lang)
+  if not ok or type(info) ~= "table" then
+    return nil
+  end
+
+  local fields = {}
+  for _, field in ipairs(info.fields or {}) do
+    fields[field] = true
+  end
+  if not fields.name and not fields.declarator then
+    return nil
+  end
+
+  -- Neovim >= 0.10 maps symbol name → is_named; older versions return {name, is_named} pairs.
+  local named_types = {}
+  for key, value in pairs(info.symbols or {}) do
+    if type(key) == "string" then
+      if value then
+        named_types[key] = true
+      end
+    elseif type(value) == "table" and value[2] then
+      named_types[value[1]] = true
+    end
+  end
+
+  local node_types = {}
+  for node_type in pairs(named_types) do
+    if not matches_any(node_type, GENERIC_IGNORED_PATTERNS) then
+      node_types[node_type] = {
+        kind = guess_kind(node_type),
+        is_scope = true,
+        name_getter = function(node, source)
+          local name_node = generic_name_node(node)
+          if name_node then
+            return vim.treesitter.get_node_text(name_node, source)
+          end
+        end,
+      }
+    end
+  end
+
+  local config = M.build(node_types)
+  config.is_generic = true
+  config.classify = function(node)
+    if not node:named() or not node_types[node:type()] then
+      return nil
+    end
+    if not generic_name_node(node) then
+      return nil
+    end
+    if has_body(node) then
+      return "scope"
+    end
+    if matches_any(node:type(), GENERIC_SYMBOL_PATTERNS) then
+      return "symbol"
+    end
+    return nil
+  end
+  return config
+end
+
+--- Rank how well a query entry names its node: captured text > literal > none.
+--- @param entry {name_node?: TSNode, name?: string}
+--- @return number
+local function name_rank(entry)
+  if entry.name_node then
+    return 2
+  end
+  return entry.name and 1 or 0
+end
+
+--- Build a LangConfig from a parsed scopes.scm query.
+---
+--- Capture names:
+---   @scope.<kind>   the node is a drillable scope of that kind (e.g. @scope.function)
+---   @symbol.<kind>  the node is a leaf symbol of that kind (e.g. @symbol.variable)
+---   @scope.name / @symbol.name / @name
+---                   the node whose text names the scope or symbol in the same match
+--- A literal name can be given with metadata instead: (#set! scope.name "if").
+--- When several patterns capture the same node, a captured name beats a
+--- literal one, which beats none.
+--- @param query vim.treesitter.Query
+--- @return LangConfig
+function M.from_query(query)
+  -- Captured nodes of the current build, keyed by TSNode:id(). Reset by prepare().
+  local entries = {}
+
+  local config = M.build({})
+  config.query = query
+
+  config.prepare = function(root, source)
+    entries = {}
+    for _, match, metadata in query:iter_matches(root, source, 0, -1, { all = true }) do
+      local target, category, kind, name_node
+      for id, nodes in pairs(match) do
+        -- Neovim 0.10 without `all` yields a single TSNode; otherwise a list.
+        local node = type(nodes) == "table" and nodes[#nodes] or nodes
+        local capture = query.captures[id]
+        local prefix, suffix = capture:match("^(%w+)%.(.+)$")
+        if capture == "name" or ((prefix == "scope" or prefix == "symbol") and suffix == "name") then
+          name_node = node
+        elseif prefix == "scope" or prefix == "symbol" then
+          target, category, kind = node, prefix, suffix
+        end
+      end
+      if target then
+        local entry = { category = category, kind = kind, name_node = name_node, name = metadata[category .. ".name"] }
+        local existing = entries[target:id()]
+        if not existing or name_rank(entry) > name_rank(existing) then
+          entries[target:id()] = entry
+        end
+      end
+    end
+  end
+
+  config.classify = function(node)
+    local entry = entries[node:id()]
+    return entry and entry.category
+  end
+
+  config.get_kind = function(node)
+    local entry = entries[node:id()]
+    return entry and entry.kind
+  end
+
+  config.get_name = function(node, source)
+    local entry = entries[node:id()]
+    if entry and entry.name then
+      return entry.name
+    end
+    if entry and entry.name_node then
+      local text = vim.trim(vim.treesitter.get_node_text(entry.name_node, source))
+      if text ~= "" then
+        return text
+      end
+    end
+    return node:type()
+  end
+
+  return config
+end
+
+-- Parsed queries, keyed by lang, parser language and query files; false
+-- records a query that failed to parse, so it is reported once.
+local _queries = {}
+
+--- Find and parse queries/<lang>/scopes.scm on the runtimepath.
+--- Files are found under the config name but parsed with the parser language,
+--- so a filename override (e.g. BUILD → python parser, bzl config) still works.
+--- The result is cached until the files found on the runtimepath change.
+--- @param lang string  lang config name
+--- @param parser_lang? string  Treesitter language (defaults to lang)
+--- @return vim.treesitter.Query|nil
+local function load_query(lang, parser_lang)
+  local files = vim.treesitter.query.get_files(lang, "scopes")
+  if #files == 0 then
+    return nil
+  end
+  parser_lang = parser_lang or lang
+  local key = lang .. "\0" .. parser_lang .. "\0" .. table.concat(files, "\0")
+  local cached = _queries[key]
+  if cached ~= nil then
+    return cached or nil
+  end
+  local contents = {}
+  for _, file in ipairs(files) do
+    table.insert(contents, table.concat(vim.fn.readfile(file), "\n"))
+  end
+  local ok, query = pcall(vim.treesitter.query.parse, parser_lang, table.concat(contents, "\n"))
+  if not ok then
+    vim.notify("scopes.nvim: invalid scopes.scm for '" .. lang .. "': " .. tostring(query), vim.log.levels.WARN)
+    _queries[key] = false
+    return nil
+  end
+  _queries[key] = query
+  return query
+end
+
 --- Load and build a LangConfig for the given language name.
---- Returns nil (with a warning) if no language file exists.
+--- A queries/<lang>/scopes.scm file on the runtimepath takes precedence over
+--- lua/scopes/languages/<lang>.lua. Returns nil if neither exists; callers
+--- may fall back to generic().
 --- @param lang string  e.g. "go", "lua"
+--- @param parser_lang? string  Treesitter language used to parse the query (defaults to lang)
 --- @return LangConfig|nil
-function M.load(lang)
+function M.load(lang, parser_lang)
+  local query = load_query(lang, parser_lang)
+  if query then
+    return M.from_query(query)
+  end
+
   local ok, node_types = pcall(require, "scopes.languages." .. lang)
   if not ok then
-    vim.notify("scopes.nvim: no language config for '" .. lang .. "'", vim.log.levels.WARN)
     return nil
   end
   return M.build(node_types)
 end
 
+--- Forget parsed queries, e.g. after editing a scopes.scm file.
+function M.clear_query_cache()
+  _queries = {}
+end
+
 return M
@@ -1,46 +1,95 @@
-local tree_mod = require("scopes.tree")
+local ScopeNode = require("scopes.tree").ScopeNode
 
 --- @class Navigator
---- @field _tree       ScopeTree
---- @field _current    ScopeNode
---- @field _breadcrumb ScopeNode[]
+--- @field _tree        ScopeTree
+--- @field _current     ScopeNode
+--- @field _breadcrumb  ScopeNode[]
+--- @field _cursor      {row: number, col?: number}|nil
+--- @field _nav_entries boolean
+--- @field _entries     table<ScopeNode, ScopeNode[]>  `..` and `.` per scope, so they keep their identity
 local Navigator = {}
 Navigator.__index = Navigator
 
 --- Create a new Navigator initialised at the tree root.
+--- With `nav_entries`, items() below the root starts with `..` and `.`.
 --- @param scope_tree ScopeTree
---- @param opts? {cursor_row?: number}
+--- @param opts? {cursor_row?: number, cursor_col?: number, nav_entries?: boolean}
 --- @return Navigator
 function Navigator.new(scope_tree, opts)
   local self = setmetatable({}, Navigator)
   self._tree = scope_tree
   self._current = scope_tree.root
   self._breadcrumb = { scope_tree.root }
+  self._nav_entries = opts and opts.nav_entries or false
+  self._entries = setmetatable({}, { __mode = "k" })
   if opts and opts.cursor_row then
-    self:open_at_cursor(opts.cursor_row)
+    self:open_at_cursor(opts.cursor_row, opts.cursor_col)
   end
   return self
 end
 
+--- Return the tree being navigated.
+--- @return ScopeTree
+function Navigator:tree()
+  return self._tree
+end
+
+--- Returns true if items() lists `..` and `.` below the root.
+--- @return boolean
+function Navigator:has_nav_entries()
+  return self._nav_entries
+end
+
 --- Return the current node (the node whose children are currently shown).
 --- @return ScopeNode
 function Navigator:current()
   return self._current
 end
 
---- Return the children of the current node.
+--- Create a synthetic entry standing for `target`. It has no children, so
+--- it is never drilled into as a scope.
+--- @param name string
+--- @param target ScopeNode
+--- @param synthetic "parent"|"self"
+--- @return ScopeNode
+local function nav_entry(name, target, synthetic)
+  local node = ScopeNode.new({ name = name, kind = target.kind, range = target.range })
+  node.synthetic = synthetic
+  node.target = target
+  return node
+end
+
+--- Return the children of the current node. With `nav_entries`, below the
+--- root they follow a `..` entry for the parent scope and a `.` entry for
+--- the current one; both carry `synthetic` ("parent" or "self") and
+--- `target` (the node they stand for).
 --- @return ScopeNode[]
 function Navigator:items()
-  return self._current.children
+  local children = self._current:expand()
+  if not self._nav_entries or self._current == self._tree.root then
+    return children
+  end
+  local entries = self._entries[self._current]
+  if not entries then
+    local parent = self._breadcrumb[#self._breadcrumb - 1]
+    entries = { nav_entry("..", parent, "parent"), nav_entry(".", self._current, "self") }
+    self._entries[self._current] = entries
+  end
+  return vim.list_extend(vim.list_slice(entries), children)
 end
 
 --- Drill down into a scope node. No-op if the node is a leaf.
+--- Drilling into a `..` entry goes up instead.
 --- @param node ScopeNode
 --- @return boolean  true if drilled, false if node is a leaf
 function Navigator:drill_down(node)
+  if node.synthetic == "parent" then
+    return self:go_up()
+  end
   if not node:is_scope() then
     return false
   end
+  node:expand()
   table.insert(self._breadcrumb, node)
   self._current = node
   return true
@@ -57,13 +106,20 @@ function Navigator:go_up()
   return true
 end
 
---- Return the start position of a node (for cursor jumping).
+--- Return the start position of a node (for cursor jumping). A `..` or `.`
+--- entry starts where the scope it stands for does.
 --- @param node ScopeNode
 --- @return {row: number, col: number}
 function Navigator:enter(node)
   return { row = node.range.start_row, col = node.range.start_col }
 end
 
+--- Return the breadcrumb path, from the root to the current node.
+--- @return ScopeNode[]
+function Navigator:breadcrumb()
+  return vim.list_slice(self._breadcrumb)
+end
+
 --- Return the breadcrumb path as a " > " separated string.
 --- @return string
 function Navigator:breadcrumb_string()
@@ -74,26 +130,58 @@ function Navigator:breadcrumb_string()
   return table.concat(parts, " > ")
 end
 
---- Navigate to the deepest scope containing `row`.
---- Rebuilds _current and _breadcrumb via parent pointers.
---- Falls back to root when no scope contains the row.
---- @param row number
-function Navigator:open_at_cursor(row)
-  local target = tree_mod.find_scope_for_row(self._tree, row)
-  if not target then
-    self._current = self._tree.root
-    self._breadcrumb = { self._tree.root }
-    return
+--- Navigate to `node`, with its ancestors as the breadcrumb.
+--- @param node ScopeNode
+function Navigator:open_at(node)
+  local chain = {}
+  local current = node
+  while current do
+    table.insert(chain, 1, current)
+    current = current.parent
+  end
+  self._breadcrumb = chain
+  self._current = node
+end
+
+--- Navigate to the deepest scope containing (`row`, `col`).
+--- The breadcrumb is the ancestor chain returned by the tree's interval index.
+--- Falls back to root when no scope contains the position. With `col` nil
+--- only rows are compared, and the first of several scopes starting on the
+--- cursor's row wins.
+--- @param row number  0-indexed
+--- @param col? number  0-indexed byte column
+function Navigator:open_at_cursor(row, col)
+  self._cursor = { row = row, col = col }
+  local _, chain = self._tree:node_at(row, col)
+  -- A trailing leaf is shown as an item of its enclosing scope.
+  while #chain > 1 and not chain[#chain]:is_scope() do
+    table.remove(chain)
+  end
+  self._breadcrumb = chain
+  self._current = chain[#chain]
+end
+
+--- Return the index in items() of the item containing the position last
+--- passed to open_at_cursor(), or nil when none of the current items does
+--- (no cursor recorded, or the navigator has moved elsewhere).
+--- @return number|nil
+function Navigator:cursor_index()
+  if not self._cursor then
+    return nil
   end
-  -- Walk parent chain to reconstruct the full path from root to target.
-  local path = {}
-  local node = target
-  while node do
-    table.insert(path, 1, node)
-    node = node.parent
+  local _, chain = self._tree:node_at(self._cursor.row, self._cursor.col)
+  for i, node in ipairs(chain) do
+    if node == self._current then
+      local target = chain[i + 1]
+      for idx, item in ipairs(self:items()) do
+        if item == target then
+          return idx
+        end
+      end
+      return nil
+    end
   end
-  self._breadcrumb = path
-  self._current = target
+  return nil
 end
 
 return Navigator
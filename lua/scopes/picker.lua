@@ -2,6 +2,8 @@ local M = {}
 
 local config = require("scopes.config")
 local icons = require("scopes.icons")
+local log = require("scopes.log")
+local Controller = require("scopes.controller")
 
 --- Convert a ScopeNode to a snacks picker item.
 --- @param node ScopeNode
@@ -20,7 +22,8 @@ function M.make_item(node, bufnr, buf_name)
   }
 end
 
---- Format a picker item for display (snacks.picker.Highlight[]).
+--- Format a picker item for display (snacks.picker.Highlight[]). The name
+--- shown is the item's text, which an adapter may set to a longer label.
 --- @param item table
 --- @param _picker any
 --- @return table
@@ -34,7 +37,7 @@ function M.format(item, _picker)
   if cfg.display.icons then
     result[#result + 1] = { icon .. " ", "SnacksPickerSpecial" }
   end
-  result[#result + 1] = { node.name, name_hl }
+  result[#result + 1] = { item.text, name_hl }
   return vim.list_extend(result, {
     { " " },
     { "[" .. node.kind .. "]", "SnacksPickerComment" },
@@ -43,148 +46,71 @@ function M.format(item, _picker)
   })
 end
 
---- Jump to a range in the given window, optionally opening a split first.
---- @param range {row: number, col: number}
---- @param split_mode "current"|"vsplit"|"hsplit"
---- @param target_win number
-local function open_at(range, split_mode, target_win)
-  if not range then
+-- Built-in adapters, loaded on first use. register() adds to this table.
+local _adapters = {
+  snacks = "scopes.pickers.snacks",
+  telescope = "scopes.pickers.telescope",
+  ["fzf-lua"] = "scopes.pickers.fzf_lua",
+  ["mini.pick"] = "scopes.pickers.mini_pick",
+  native = "scopes.pickers.native",
+}
+
+--- Register a picker adapter under `name`, for use as picker.backend.
+--- See lua/scopes/controller.lua for the adapter contract.
+--- @param name string
+--- @param adapter {available?: fun(): boolean, open: fun(ctrl: scopes.Controller): scopes.PickerView|nil}
+function M.register(name, adapter)
+  if type(name) ~= "string" or type(adapter) ~= "table" or type(adapter.open) ~= "function" then
+    vim.notify(
+      "scopes.nvim: picker.register(): expected a name and a table with an open() function",
+      vim.log.levels.WARN
+    )
     return
   end
-  if not vim.api.nvim_win_is_valid(target_win) then
-    return
-  end
-  -- Ensure focus is on target_win before splitting; no-op for "current" mode.
-  vim.api.nvim_set_current_win(target_win)
-  local dest_win
-  if split_mode == "vsplit" then
-    dest_win = vim.api.nvim_open_win(0, true, { split = "right" })
-  elseif split_mode == "hsplit" then
-    dest_win = vim.api.nvim_open_win(0, true, { split = "below" })
-  elseif split_mode == "current" then
-    dest_win = target_win
-  else
-    vim.notify("scopes.nvim: unknown split_mode: " .. tostring(split_mode), vim.log.levels.WARN)
-    return
+  _adapters[name] = adapter
+end
+
+--- Return the adapter registered as `name`, loading a built-in one if needed.
+--- @param name string
+--- @return table|nil
+local function resolve(name)
+  local adapter = _adapters[name]
+  if type(adapter) == "string" then
+    adapter = require(adapter)
+    _adapters[name] = adapter
   end
-  vim.api.nvim_win_set_cursor(dest_win, { range.row + 1, range.col })
+  return adapter
 end
 
---- Open the scope picker for the given navigator.
+--- Open the scope picker for the given navigator with the adapter selected by
+--- picker.backend. When that picker is not installed, the built-in floating
+--- picker is used instead.
+--- Returns the controller, whose `refresh()` re-reads the navigator's items
+--- and breadcrumb, e.g. while an async build is still filling in the tree;
+--- `refresh({ focus_cursor = true })` also moves the selection to the item
+--- containing the cursor.
 --- @param nav Navigator
 --- @param bufnr number
+--- @return scopes.Controller|nil
 function M.open(nav, bufnr)
-  local ok, Snacks = pcall(require, "snacks")
-  if not ok then
-    vim.notify("scopes.nvim: snacks.nvim is required", vim.log.levels.ERROR)
-    return
+  local name = config.get().picker.backend
+  local adapter = resolve(name)
+  if not adapter then
+    vim.notify(
+      "scopes.nvim: unknown picker.backend '" .. tostring(name) .. "', using the built-in picker",
+      vim.log.levels.WARN
+    )
+    adapter = resolve("native")
+  elseif adapter.available and not adapter.available() then
+    log.debug("picker " .. name .. " not available, using the built-in picker")
+    adapter = resolve("native")
   end
 
-  local buf_name = vim.api.nvim_buf_get_name(bufnr)
-  local main_win = vim.api.nvim_get_current_win()
-  local original_cursor = vim.api.nvim_win_get_cursor(main_win)
-  local confirmed = false
-  local cfg = config.get()
-
-  Snacks.picker({
-    title = nav:breadcrumb_string(),
-
-    layout = {
-      layout = {
-        width = cfg.picker.width,
-        height = cfg.picker.height,
-        border = cfg.picker.border,
-      },
-    },
-
-    finder = function()
-      local items = {}
-      for _, node in ipairs(nav:items()) do
-        items[#items + 1] = M.make_item(node, bufnr, buf_name)
-      end
-      return items
-    end,
-
-    format = M.format,
-
-    confirm = function(picker, item)
-      if not item then
-        return
-      end
-      confirmed = true
-      local pos = nav:enter(item.node)
-      picker:close()
-      open_at(pos, "current", main_win)
-    end,
-
-    on_close = function(_picker)
-      if not confirmed and vim.api.nvim_win_is_valid(main_win) then
-        vim.api.nvim_win_set_cursor(main_win, original_cursor)
-      end
-    end,
-
-    actions = {
-      scope_drill = function(picker)
-        local item = picker:current({ resolve = false })
-        if item and nav:drill_down(item.node) then
-          picker.title = nav:breadcrumb_string()
-          picker:refresh()
-        end
-      end,
-
-      scope_up = function(picker)
-        -- Focus on the node's parent when going up in scope
-        local prev_node = nav:current()
-        if nav:go_up() then
-          picker.title = nav:breadcrumb_string()
-          picker:find({
-            refresh = true,
-            on_done = function()
-              for item, idx in picker:iter() do
-                if item.node == prev_node then
-                  picker.list:view(idx)
-                  return
-                end
-              end
-            end,
-          })
-        end
-      end,
-
-      scope_split_v = function(picker)
-        local item = picker:current({ resolve = false })
-        if not item then
-          return
-        end
-        confirmed = true
-        local pos = nav:enter(item.node)
-        picker:close()
-        open_at(pos, "vsplit", main_win)
-      end,
-
-      scope_split_h = function(picker)
-        local item = picker:current({ resolve = false })
-        if not item then
-          return
-        end
-        confirmed = true
-        local pos = nav:enter(item.node)
-        picker:close()
-        open_at(pos, "hsplit", main_win)
-      end,
-    },
-
-    win = {
-      input = {
-        keys = {
-          ["<Tab>"] = { "scope_drill", mode = { "i", "n" } },
-          ["<S-Tab>"] = { "scope_up", mode = { "i", "n" } },
-          [cfg.picker.split_vertical] = { "scope_split_v", mode = { "i", "n" } },
-          [cfg.picker.split_horizontal] = { "scope_split_h", mode = { "i", "n" } },
-        },
-      },
-    },
-  })
+  local ctrl = Controller.new(nav, bufnr)
+  if not ctrl:attach(adapter) then
+    return nil
+  end
+  return ctrl
 end
 
 return M
@@ -0,0 +1,297 @@
+--- fzf-lua adapter for scopes.nvim
+--- Feeds the controller's items to fzf_exec. Drill-down, go-up and the
+--- levels and recursive lists are reload actions: the list is regenerated
+--- from the items last shown, with the breadcrumb as its first line, which
+--- fzf shows as the header (--header-lines=1).
+--- The position to select is written to a file that a `load` bind reads
+--- after the next (re)load, which needs fzf 0.45 or later for `transform`.
+
+local config = require("scopes.config")
+
+local M = {}
+
+--- Hidden key bound to a no-op reload; sent to fzf's terminal when items or
+--- focus change outside a reload action.
+local RELOAD_KEY = "ctrl-alt-r"
+local RELOAD_BYTES = "\27\18"
+--- Hidden key bound to fzf's abort; sent to fzf's terminal by close().
+local ABORT_KEY = "ctrl-alt-x"
+local ABORT_BYTES = "\27\24"
+
+--- ANSI color for each part role (see Controller:parts()); nil leaves it plain.
+local COLORS = {
+  error = "red",
+  kind = "grey",
+  line = "green",
+  drill = "blue",
+}
+
+--- Format one list line: "<index>\t<display>". fzf shows only the display
+--- (--with-nth=2..); the index maps a selection back to its node.
+--- @param ctrl scopes.Controller
+--- @param index number
+--- @param node ScopeNode
+--- @return string
+local function make_line(ctrl, index, node)
+  local utils = require("fzf-lua.utils")
+  local parts = {}
+  for _, part in ipairs(ctrl:parts(node)) do
+    local color = COLORS[part.role]
+    table.insert(parts, color and utils.ansi_codes[color](part.text) or part.text)
+  end
+  return index .. "\t" .. table.concat(parts, " ")
+end
+
+--- Translate a Neovim key like "<S-Tab>" to fzf's "shift-tab".
+--- @param key string
+--- @return string
+local function fzf_key(key)
+  local inner = key:match("^<(.+)>$") or key
+  inner = inner:lower():gsub("^s%-", "shift-"):gsub("^c%-", "ctrl-"):gsub("^m%-", "alt-"):gsub("^a%-", "alt-")
+  return inner == "cr" and "enter" or inner
+end
+
+--- Returns true if fzf-lua is installed.
+--- @return boolean
+function M.available()
+  return (pcall(require, "fzf-lua"))
+end
+
+--- Open the scope picker in fzf-lua.
+--- fzf is started from vim.schedule so that the first focus (the item under
+--- the cursor) arrives before it does. Later changes made outside a reload
+--- action, e.g. by an async build, reload fzf by sending RELOAD_KEY to its
+--- terminal.
+--- @param ctrl scopes.Controller
+--- @return scopes.PickerView|nil
+function M.open(ctrl)
+  local ok, fzf = pcall(require, "fzf-lua")
+  if not ok then
+    vim.notify("scopes.nvim: fzf-lua is required for picker.backend = 'fzf-lua'", vim.log.levels.ERROR)
+    return nil
+  end
+
+  local bufnr = ctrl.bufnr
+  local cfg = config.get()
+
+  local view = { items = ctrl:items(), title = ctrl:title(), started = false, reopening = false }
+  -- fzf's terminal buffer, set once its window is created.
+  local fzf_buf = nil
+  -- True while an action runs: fzf reloads (or exits) right after on its own.
+  local in_action = false
+  local reload_pending = false
+  local pos_file = vim.fn.tempname()
+
+  --- Select `index` at the next (re)load, or the first item when nil.
+  --- @param index? number
+  local function set_pos(index)
+    vim.fn.writefile({ index and ("pos(" .. index .. ")") or "first" }, pos_file)
+  end
+
+  --- Send `bytes` to fzf's terminal, as if typed. False once fzf is gone.
+  --- @param bytes string
+  --- @return boolean
+  local function send(bytes)
+    if not fzf_buf or not vim.api.nvim_buf_is_valid(fzf_buf) then
+      return false
+    end
+    local chan = vim.bo[fzf_buf].channel
+    return chan > 0 and pcall(vim.api.nvim_chan_send, chan, bytes)
+  end
+
+  --- Make a running fzf re-read the list, once per batch of changes.
+  local function request_reload()
+    if in_action or not view.started or reload_pending then
+      return
+    end
+    reload_pending = true
+    vim.schedule(function()
+      reload_pending = false
+      send(RELOAD_BYTES)
+    end)
+  end
+
+  --- Return the node a selected line refers to, among the items last shown.
+  --- @param line? string
+  --- @return ScopeNode|nil
+  local function node_of(line)
+    local index = line and tonumber(line:match("^(%d+)\t"))
+    return index and view.items[index]
+  end
+
+  local function contents(fzf_cb)
+    fzf_cb(view.title)
+    for index, node in ipairs(view.items) do
+      fzf_cb(make_line(ctrl, index, node))
+    end
+    fzf_cb()
+  end
+
+  --- Preview the node's whole range, copied from the buffer into a scratch
+  --- buffer highlighted with the buffer's Treesitter language.
+  local builtin = require("fzf-lua.previewer.builtin")
+  local Previewer = builtin.base:extend()
+
+  function Previewer:new(o, opts, fzf_win)
+    Previewer.super.new(self, o, opts, fzf_win)
+    setmetatable(self, Previewer)
+    return self
+  end
+
+  function Previewer:populate_preview_buf(entry_str)
+    local node = node_of(entry_str)
+    -- Called for each newly focused line: peek at it in the main window too.
+    vim.schedule(function()
+      ctrl:preview(node)
+    end)
+    if not node then
+      return
+    end
+    local tmpbuf = self:get_tmp_buffer()
+    local lines = vim.api.nvim_buf_get_lines(bufnr, node.range.start_row, node.range.end_row + 1, false)
+    vim.api.nvim_buf_set_lines(tmpbuf, 0, -1, false, lines)
+    local lang = vim.treesitter.language.get_lang(vim.bo[bufnr].filetype)
+    if lang then
+      pcall(vim.treesitter.start, tmpbuf, lang)
+    end
+    self:set_preview_buf(tmpbuf)
+    self.win:update_preview_scrollbar()
+  end
+
+  --- Build an action running `fn` on the selected node. With `reload`, fzf
+  --- re-reads the list after it; otherwise fzf has already exited.
+  --- @param fn fun(node: ScopeNode|nil)
+  --- @param reload boolean
+  --- @return table
+  local function action(fn, reload)
+    return {
+      fn = function(selected)
+        in_action = true
+        fn(node_of(selected[1]))
+        in_action = false
+      end,
+      reload = reload,
+    }
+  end
+
+  local start
+
+  --- Build a closing action that jumps to the selected node. A confirm that
+  --- moves within the picker (a listed level, `..`) starts fzf again on the
+  --- new level, since fzf has already exited.
+  --- @param split_mode "current"|"vsplit"|"hsplit"
+  --- @return table
+  local function jump(split_mode)
+    return action(function(node)
+      if not node then
+        return
+      end
+      if ctrl:navigates(node) then
+        view.reopening = true
+        view.started = false
+        ctrl:confirm(node, split_mode)
+        start()
+        return
+      end
+      ctrl:confirm(node, split_mode)
+    end, false)
+  end
+
+  --- Start fzf on the items last shown, from vim.schedule so that a focus
+  --- given right after arrives first.
+  start = function()
+    vim.schedule(function()
+      if ctrl:is_closed() then
+        os.remove(pos_file)
+        return
+      end
+      view.started = true
+      local file = vim.fn.shellescape(pos_file)
+      local fzf_opts = {
+        ["--header-lines"] = 1,
+        ["--delimiter"] = "\t",
+        ["--with-nth"] = "2..",
+        -- Consumed by the load that applies it, so a plain reload keeps fzf's position.
+        ["--bind"] = "load:transform(cat " .. file .. " 2>/dev/null; rm -f " .. file .. ")," .. ABORT_KEY .. ":abort",
+      }
+
+      fzf.fzf_exec(contents, {
+        prompt = "> ",
+        fzf_opts = fzf_opts,
+        previewer = cfg.picker.preview and Previewer or nil,
+        winopts = {
+          width = cfg.picker.width,
+          height = cfg.picker.height,
+          border = cfg.picker.border,
+          on_create = function()
+            fzf_buf = vim.api.nvim_get_current_buf()
+          end,
+          on_close = function()
+            fzf_buf = nil
+            -- Checked once the closing action has run: it may start fzf again.
+            vim.schedule(function()
+              if view.reopening then
+                view.reopening = false
+                return
+              end
+              os.remove(pos_file)
+              ctrl:closed()
+            end)
+          end,
+        },
+        actions = {
+          ["default"] = jump("current"),
+          [fzf_key(cfg.picker.split_vertical)] = jump("vsplit"),
+          [fzf_key(cfg.picker.split_horizontal)] = jump("hsplit"),
+          [fzf_key(cfg.picker.drill_down)] = action(function(node)
+            ctrl:drill(node)
+          end, true),
+          [fzf_key(cfg.picker.go_up)] = action(function()
+            ctrl:up()
+          end, true),
+          [fzf_key(cfg.picker.levels)] = action(function()
+            ctrl:toggle_levels()
+          end, true),
+          [fzf_key(cfg.picker.recursive)] = action(function()
+            ctrl:toggle_recursive()
+          end, true),
+          [RELOAD_KEY] = action(function() end, true),
+        },
+      })
+    end)
+  end
+
+  start()
+
+  -- contents() reads view.items and view.title, so show() and set_title()
+  -- store them, and fzf picks them up at the next (re)load.
+  function view.show(_, items)
+    view.items = items
+    set_pos(nil)
+    request_reload()
+  end
+
+  function view.set_title(_, title)
+    view.title = title
+  end
+
+  function view.refresh()
+    request_reload()
+  end
+
+  function view.focus(_, index)
+    set_pos(index)
+    request_reload()
+  end
+
+  function view.close()
+    -- A closing action has already ended fzf; otherwise abort it as <Esc> would.
+    if not in_action then
+      send(ABORT_BYTES)
+    end
+  end
+
+  return view
+end
+
+return M
@@ -0,0 +1,258 @@
+--- mini.pick adapter for scopes.nvim
+--- Shows the controller's items with MiniPick.start. Drill-down and go-up
+--- swap the items with MiniPick.set_picker_items and put the breadcrumb in
+--- the prompt.
+
+local config = require("scopes.config")
+
+local M = {}
+
+--- mini.pick's own default keys, which our mappings take over when they share a key.
+local BUILTIN_KEYS = {
+  choose_in_split = "<C-s>",
+  choose_in_vsplit = "<C-v>",
+  paste = "<C-r>",
+  scroll_up = "<C-b>",
+  toggle_info = "<S-Tab>",
+  toggle_preview = "<Tab>",
+}
+
+--- Convert a ScopeNode to a mini.pick item. The bufnr/lnum/col fields let
+--- MiniPick.default_preview show and highlight the node's range.
+--- @param ctrl scopes.Controller
+--- @param node ScopeNode
+--- @return table
+local function make_item(ctrl, node)
+  local parts = vim.tbl_map(function(part)
+    return part.text
+  end, ctrl:parts(node))
+  return {
+    text = table.concat(parts, " "),
+    node = node,
+    bufnr = ctrl.bufnr,
+    lnum = node.range.start_row + 1,
+    col = node.range.start_col + 1,
+    end_lnum = node.range.end_row + 1,
+    end_col = node.range.end_col + 1,
+  }
+end
+
+--- Returns true if mini.pick is installed.
+--- @return boolean
+function M.available()
+  return (pcall(require, "mini.pick"))
+end
+
+--- Open the scope picker in mini.pick.
+--- MiniPick.start() blocks until the picker closes, so it is started from
+--- vim.schedule and the view is returned right away.
+--- @param ctrl scopes.Controller
+--- @return scopes.PickerView|nil
+function M.open(ctrl)
+  local ok, MiniPick = pcall(require, "mini.pick")
+  if not ok then
+    vim.notify("scopes.nvim: mini.pick is required for picker.backend = 'mini.pick'", vim.log.levels.ERROR)
+    return nil
+  end
+
+  local cfg = config.get()
+  local view = { items = ctrl:items(), title = ctrl:title(), active = false, initial = nil }
+
+  local function items()
+    return vim.tbl_map(function(node)
+      return make_item(ctrl, node)
+    end, view.items)
+  end
+
+  --- Select `node` once the new items have been matched.
+  --- @param node? ScopeNode
+  local function select(node)
+    if not node then
+      return
+    end
+    vim.schedule(function()
+      if not MiniPick.is_picker_active() then
+        return
+      end
+      for index, item in ipairs(MiniPick.get_picker_items() or {}) do
+        if item.node == node then
+          MiniPick.set_picker_match_inds({ index }, "current")
+          return
+        end
+      end
+    end)
+  end
+
+  local function live()
+    return view.active and MiniPick.is_picker_active()
+  end
+
+  local function current_node()
+    local matches = MiniPick.get_picker_matches()
+    return matches and matches.current and matches.current.node
+  end
+
+  --- Jump to the current item once the picker has closed. A confirm that
+  --- moves within the picker (a listed level, `..`) keeps it open.
+  --- @param split_mode "current"|"vsplit"|"hsplit"
+  --- @return boolean  true tells mini.pick to stop.
+  local function jump(split_mode)
+    local node = current_node()
+    if ctrl:navigates(node) then
+      ctrl:confirm(node, split_mode)
+      return false
+    end
+    if node then
+      vim.schedule(function()
+        ctrl:confirm(node, split_mode)
+      end)
+    end
+    return true
+  end
+
+  local mappings = {
+    scope_drill = {
+      char = cfg.picker.drill_down,
+      func = function()
+        local node = current_node()
+        if node then
+          ctrl:drill(node)
+        end
+      end,
+    },
+    scope_up = {
+      char = cfg.picker.go_up,
+      func = function()
+        ctrl:up()
+      end,
+    },
+    scope_levels = {
+      char = cfg.picker.levels,
+      func = function()
+        ctrl:toggle_levels()
+      end,
+    },
+    scope_recursive = {
+      char = cfg.picker.recursive,
+      func = function()
+        ctrl:toggle_recursive()
+      end,
+    },
+    scope_split_v = {
+      char = cfg.picker.split_vertical,
+      func = function()
+        return jump("vsplit")
+      end,
+    },
+    scope_split_h = {
+      char = cfg.picker.split_horizontal,
+      func = function()
+        return jump("hsplit")
+      end,
+    },
+  }
+  local ours = {}
+  for _, mapping in pairs(mappings) do
+    ours[mapping.char] = true
+  end
+  for name, key in pairs(BUILTIN_KEYS) do
+    if ours[key] then
+      mappings[name] = ""
+    end
+  end
+
+  local group = vim.api.nvim_create_augroup("scopes_mini_pick", { clear = true })
+  vim.api.nvim_create_autocmd("User", {
+    group = group,
+    pattern = "MiniPickStart",
+    once = true,
+    callback = function()
+      select(view.initial and view.items[view.initial])
+    end,
+  })
+
+  vim.schedule(function()
+    if ctrl:is_closed() then
+      return
+    end
+    view.active = true
+    MiniPick.start({
+      source = {
+        name = "Scopes",
+        items = items(),
+        choose = function(item)
+          -- Returning true keeps the picker open.
+          if item and ctrl:navigates(item.node) then
+            ctrl:confirm(item.node, "current")
+            return true
+          end
+          if item then
+            vim.schedule(function()
+              ctrl:confirm(item.node, "current")
+            end)
+          end
+        end,
+        preview = MiniPick.default_preview,
+        -- Redrawn whenever the matches or the current item change: peek at
+        -- the current item in the main window.
+        show = function(buf_id, items_to_show, query)
+          MiniPick.default_show(buf_id, items_to_show, query)
+          if cfg.picker.preview then
+            vim.schedule(function()
+              if live() then
+                ctrl:preview(current_node())
+              end
+            end)
+          end
+        end,
+      },
+      mappings = mappings,
+      window = {
+        prompt_prefix = view.title .. " > ",
+        config = cfg.picker.border and { border = cfg.picker.border } or nil,
+      },
+    })
+    view.active = false
+    -- A confirm is scheduled after this; it still jumps once closed.
+    ctrl:closed()
+  end)
+
+  function view.show(_, new_items)
+    view.items = new_items
+    if live() then
+      MiniPick.set_picker_query({})
+      MiniPick.set_picker_items(items())
+    end
+  end
+
+  function view.set_title(_, title)
+    view.title = title
+    if live() then
+      MiniPick.set_picker_opts({ window = { prompt_prefix = title .. " > " } })
+    end
+  end
+
+  function view.refresh()
+    if live() then
+      MiniPick.set_picker_items(items())
+    end
+  end
+
+  function view.focus(_, index)
+    if not view.active then
+      view.initial = index
+      return
+    end
+    select(view.items[index])
+  end
+
+  function view.close()
+    if live() then
+      MiniPick.stop()
+    end
+  end
+
+  return view
+end
+
+return M
@@ -0,0 +1,336 @@
+--- Built-in floating picker for scopes.nvim
+--- Needs nothing beyond Neovim: a one-line prompt float, a result list float
+--- below it and, with picker.preview, a float showing the buffer at the
+--- selected node. Typing filters the list with matchfuzzy(); the breadcrumb
+--- is the prompt's title. Used whenever the configured picker is missing.
+
+local config = require("scopes.config")
+
+local M = {}
+
+local NS = vim.api.nvim_create_namespace("scopes_native_picker")
+
+--- Highlight group for each part role (see Controller:parts()).
+local HL = {
+  icon = "Special",
+  name = "Identifier",
+  error = "DiagnosticError",
+  kind = "Comment",
+  line = "LineNr",
+  drill = "Directory",
+}
+
+--- Resolve a width/height option: a fraction (<= 1) of `total`, or an
+--- absolute size capped at `total`.
+--- @param value? number
+--- @param total number
+--- @param default number
+--- @return number
+local function dimension(value, total, default)
+  value = value or default
+  if value <= 1 then
+    return math.max(1, math.floor(total * value))
+  end
+  return math.max(1, math.min(math.floor(value), total))
+end
+
+--- Compute the float configs for the prompt, list and (optional) preview.
+--- @param cfg scopes.PickerConfig
+--- @return table prompt, table list, table|nil preview
+local function layout(cfg)
+  local border = cfg.border or "rounded"
+  local frame = border == "none" and 0 or 2
+  local columns = vim.o.columns
+  local lines = vim.o.lines - vim.o.cmdheight
+
+  local width = dimension(cfg.width, columns, 0.8)
+  local height = math.max(dimension(cfg.height, lines, 0.6), 2 * frame + 2)
+  local row = math.floor((lines - height) / 2)
+  local col = math.floor((columns - width) / 2)
+  local body_row = row + 1 + frame
+  local body_height = math.max(1, height - 1 - 2 * frame)
+  local list_width = cfg.preview and math.floor(width / 2) or width
+
+  local base = { relative = "editor", style = "minimal", border = border }
+  local prompt = vim.tbl_extend("force", base, {
+    row = row,
+    col = col,
+    width = math.max(1, width - frame),
+    height = 1,
+  })
+  local list = vim.tbl_extend("force", base, {
+    row = body_row,
+    col = col,
+    width = math.max(1, list_width - frame),
+    height = body_height,
+    focusable = false,
+  })
+  local preview = nil
+  if cfg.preview then
+    preview = vim.tbl_extend("force", base, {
+      row = body_row,
+      col = col + list_width,
+      width = math.max(1, width - list_width - frame),
+      height = body_height,
+      focusable = false,
+    })
+  end
+  return prompt, list, preview
+end
+
+--- Create a scratch buffer that is wiped when its window closes.
+--- @return number
+local function scratch()
+  local buf = vim.api.nvim_create_buf(false, true)
+  vim.bo[buf].bufhidden = "wipe"
+  return buf
+end
+
+--- Open the built-in picker. Always available.
+--- @param ctrl scopes.Controller
+--- @return scopes.PickerView
+function M.open(ctrl)
+  local cfg = config.get().picker
+  local prompt_cfg, list_cfg, preview_cfg = layout(cfg)
+  local has_title = prompt_cfg.border ~= "none"
+
+  local view = {
+    items = ctrl:items(),
+    --- Indices into `items` of the entries matching the query, in match order.
+    filtered = {},
+    --- Position in `filtered` of the selected entry.
+    selected = 1,
+    closed = false,
+  }
+
+  view.prompt_buf = scratch()
+  view.list_buf = scratch()
+  if has_title then
+    prompt_cfg.title = " " .. ctrl:title() .. " "
+    prompt_cfg.title_pos = "center"
+  end
+  view.list_win = vim.api.nvim_open_win(view.list_buf, false, list_cfg)
+  vim.wo[view.list_win].cursorline = true
+  if preview_cfg then
+    view.preview_win = vim.api.nvim_open_win(ctrl.bufnr, false, preview_cfg)
+    vim.wo[view.preview_win].number = true
+    vim.wo[view.preview_win].cursorline = true
+  end
+  view.prompt_win = vim.api.nvim_open_win(view.prompt_buf, true, prompt_cfg)
+
+  --- Return the selected node, if any entry matches.
+  --- @return ScopeNode|nil
+  function view.selected_node()
+    local index = view.filtered[view.selected]
+    return index and view.items[index]
+  end
+
+  --- Peek at the selected node and show its start in the preview float. The
+  --- peek highlights the range in the buffer, so the float shows it too.
+  local function update_preview()
+    local node = view.selected_node()
+    ctrl:preview(node)
+    if not node or not view.preview_win or not vim.api.nvim_win_is_valid(view.preview_win) then
+      return
+    end
+    local r = node.range
+    vim.api.nvim_win_set_cursor(view.preview_win, { r.start_row + 1, r.start_col })
+    vim.api.nvim_win_call(view.preview_win, function()
+      vim.cmd("normal! zt")
+    end)
+  end
+
+  --- Move the list cursor to the selected entry.
+  local function update_selection()
+    if #view.filtered == 0 then
+      update_preview()
+      return
+    end
+    view.selected = math.max(1, math.min(view.selected, #view.filtered))
+    vim.api.nvim_win_set_cursor(view.list_win, { view.selected, 0 })
+    update_preview()
+  end
+
+  --- Redraw the list from `filtered`.
+  local function render()
+    local lines = {}
+    local marks = {}
+    for i, index in ipairs(view.filtered) do
+      local text = ""
+      for j, part in ipairs(ctrl:parts(view.items[index])) do
+        if j > 1 then
+          text = text .. " "
+        end
+        table.insert(marks, { i - 1, #text, #text + #part.text, HL[part.role] })
+        text = text .. part.text
+      end
+      lines[i] = text
+    end
+    vim.api.nvim_buf_set_lines(view.list_buf, 0, -1, false, lines)
+    vim.api.nvim_buf_clear_namespace(view.list_buf, NS, 0, -1)
+    for _, mark in ipairs(marks) do
+      vim.api.nvim_buf_set_extmark(view.list_buf, NS, mark[1], mark[2], { end_col = mark[3], hl_group = mark[4] })
+    end
+  end
+
+  --- Re-filter `items` by the prompt text, keeping the selected item if it
+  --- still matches.
+  local function filter()
+    local previous = view.filtered[view.selected]
+    local query = vim.api.nvim_buf_get_lines(view.prompt_buf, 0, 1, false)[1] or ""
+    view.filtered = {}
+    if query == "" then
+      for i = 1, #view.items do
+        view.filtered[i] = i
+      end
+    else
+      local entries = {}
+      for i, node in ipairs(view.items) do
+        entries[i] = { text = ctrl:label(node), index = i }
+      end
+      for _, entry in ipairs(vim.fn.matchfuzzy(entries, query, { key = "text" })) do
+        table.insert(view.filtered, entry.index)
+      end
+    end
+    view.selected = 1
+    for pos, index in ipairs(view.filtered) do
+      if index == previous then
+        view.selected = pos
+        break
+      end
+    end
+    render()
+    update_selection()
+  end
+
+  --- Move the selection by `delta`, wrapping around.
+  --- @param delta number
+  local function move(delta)
+    local count = #view.filtered
+    if count == 0 then
+      return
+    end
+    view.selected = (view.selected - 1 + delta) % count + 1
+    update_selection()
+  end
+
+  local function with_selected(fn)
+    return function()
+      local node = view.selected_node()
+      if node then
+        fn(node)
+      end
+    end
+  end
+
+  local group = vim.api.nvim_create_augroup("scopes_native_picker", { clear = true })
+
+  function view.show(_, items)
+    view.items = items
+    -- The query is kept; a new level starts at its first match.
+    view.filtered = {}
+    filter()
+  end
+
+  function view.set_title(_, title)
+    if has_title and vim.api.nvim_win_is_valid(view.prompt_win) then
+      vim.api.nvim_win_set_config(view.prompt_win, { title = " " .. title .. " ", title_pos = "center" })
+    end
+  end
+
+  function view.refresh()
+    filter()
+  end
+
+  function view.focus(_, index)
+    for pos, i in ipairs(view.filtered) do
+      if i == index then
+        view.selected = pos
+        update_selection()
+        return
+      end
+    end
+  end
+
+  function view.close()
+    if view.closed then
+      return
+    end
+    view.closed = true
+    vim.api.nvim_del_augroup_by_id(group)
+    vim.cmd("stopinsert")
+    for _, win in ipairs({ view.prompt_win, view.list_win, view.preview_win }) do
+      if win and vim.api.nvim_win_is_valid(win) then
+        vim.api.nvim_win_close(win, true)
+      end
+    end
+    if vim.api.nvim_win_is_valid(ctrl.main_win) then
+      vim.api.nvim_set_current_win(ctrl.main_win)
+    end
+    ctrl:closed()
+  end
+
+  vim.api.nvim_create_autocmd({ "TextChanged", "TextChangedI" }, {
+    group = group,
+    buffer = view.prompt_buf,
+    callback = filter,
+  })
+  -- Clicking or jumping elsewhere dismisses the picker.
+  vim.api.nvim_create_autocmd("WinLeave", {
+    group = group,
+    buffer = view.prompt_buf,
+    callback = function()
+      vim.schedule(view.close)
+    end,
+  })
+
+  local keys = {
+    [cfg.enter] = with_selected(function(node)
+      ctrl:confirm(node, "current")
+    end),
+    [cfg.drill_down] = with_selected(function(node)
+      ctrl:drill(node)
+    end),
+    [cfg.go_up] = function()
+      ctrl:up()
+    end,
+    [cfg.levels] = function()
+      ctrl:toggle_levels()
+    end,
+    [cfg.recursive] = function()
+      ctrl:toggle_recursive()
+    end,
+    [cfg.split_vertical] = with_selected(function(node)
+      ctrl:confirm(node, "vsplit")
+    end),
+    [cfg.split_horizontal] = with_selected(function(node)
+      ctrl:confirm(node, "hsplit")
+    end),
+    ["<C-n>"] = function()
+      move(1)
+    end,
+    ["<Down>"] = function()
+      move(1)
+    end,
+    ["<C-p>"] = function()
+      move(-1)
+    end,
+    ["<Up>"] = function()
+      move(-1)
+    end,
+  }
+  for key, fn in pairs(keys) do
+    vim.keymap.set({ "i", "n" }, key, fn, { buffer = view.prompt_buf, nowait = true })
+  end
+  for _, key in ipairs(cfg.close or {}) do
+    -- Plain characters like "q" close from normal mode only, so they can be typed.
+    local modes = key:sub(1, 1) == "<" and { "i", "n" } or { "n" }
+    vim.keymap.set(modes, key, view.close, { buffer = view.prompt_buf, nowait = true })
+  end
+
+  filter()
+  vim.cmd("startinsert")
+  return view
+end
+
+return M
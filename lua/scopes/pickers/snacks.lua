@@ -0,0 +1,182 @@
+--- snacks.picker adapter for scopes.nvim
+--- Drill-down and go-up re-run the finder over the items the controller
+--- last showed; focus waits for the list to finish loading.
+
+local config = require("scopes.config")
+local picker_mod = require("scopes.picker")
+
+local M = {}
+
+--- Returns true if snacks.nvim is installed.
+--- @return boolean
+function M.available()
+  return (pcall(require, "snacks"))
+end
+
+--- Open the scope picker in snacks.picker.
+--- @param ctrl scopes.Controller
+--- @return scopes.PickerView|nil
+function M.open(ctrl)
+  local ok, Snacks = pcall(require, "snacks")
+  if not ok then
+    vim.notify("scopes.nvim: snacks.nvim is required", vim.log.levels.ERROR)
+    return nil
+  end
+
+  local bufnr = ctrl.bufnr
+  local buf_name = vim.api.nvim_buf_get_name(bufnr)
+  local cfg = config.get()
+
+  local view = { items = ctrl:items(), shown = false, loading = false, pending = nil }
+  local picker
+
+  --- Select the item at `index` (of view.items) once the list has it.
+  --- @param index number
+  local function select(index)
+    local node = view.items[index]
+    for item, idx in picker:iter() do
+      if item.node == node then
+        picker.list:view(idx)
+        return
+      end
+    end
+  end
+
+  picker = Snacks.picker({
+    title = ctrl:title(),
+
+    layout = {
+      layout = {
+        width = cfg.picker.width,
+        height = cfg.picker.height,
+        border = cfg.picker.border,
+      },
+    },
+
+    finder = function()
+      local items = {}
+      for _, node in ipairs(view.items) do
+        local item = picker_mod.make_item(node, bufnr, buf_name)
+        -- Match on the node's path while listing recursively.
+        item.text = ctrl:label(node)
+        items[#items + 1] = item
+      end
+      return items
+    end,
+
+    format = picker_mod.format,
+
+    on_show = function(p)
+      view.shown = true
+      if view.pending and not view.loading then
+        -- The query is still empty, so the list order is the items order,
+        -- and the list keeps the target even while items are arriving.
+        p.list:view(view.pending)
+        view.pending = nil
+      end
+    end,
+
+    confirm = function(_, item)
+      if item then
+        ctrl:confirm(item.node, "current")
+      end
+    end,
+
+    -- Peek at the highlighted item in the main window (picker.preview).
+    on_change = function(_, item)
+      ctrl:preview(item and item.node)
+    end,
+
+    on_close = function()
+      ctrl:closed()
+    end,
+
+    actions = {
+      scope_drill = function(p)
+        local item = p:current({ resolve = false })
+        if item then
+          ctrl:drill(item.node)
+        end
+      end,
+
+      scope_up = function()
+        ctrl:up()
+      end,
+
+      scope_levels = function()
+        ctrl:toggle_levels()
+      end,
+
+      scope_recursive = function()
+        ctrl:toggle_recursive()
+      end,
+
+      scope_split_v = function(p)
+        local item = p:current({ resolve = false })
+        if item then
+          ctrl:confirm(item.node, "vsplit")
+        end
+      end,
+
+      scope_split_h = function(p)
+        local item = p:current({ resolve = false })
+        if item then
+          ctrl:confirm(item.node, "hsplit")
+        end
+      end,
+    },
+
+    win = {
+      input = {
+        keys = {
+          [cfg.picker.drill_down] = { "scope_drill", mode = { "i", "n" } },
+          [cfg.picker.go_up] = { "scope_up", mode = { "i", "n" } },
+          [cfg.picker.levels] = { "scope_levels", mode = { "i", "n" } },
+          [cfg.picker.recursive] = { "scope_recursive", mode = { "i", "n" } },
+          [cfg.picker.split_vertical] = { "scope_split_v", mode = { "i", "n" } },
+          [cfg.picker.split_horizontal] = { "scope_split_h", mode = { "i", "n" } },
+        },
+      },
+    },
+  })
+
+  function view.show(_, items)
+    view.items = items
+    view.loading = true
+    picker:find({
+      refresh = true,
+      on_done = function()
+        view.loading = false
+        if view.pending then
+          local index = view.pending
+          view.pending = nil
+          select(index)
+        end
+      end,
+    })
+  end
+
+  function view.set_title(_, title)
+    picker.title = title
+  end
+
+  function view.refresh()
+    picker:refresh()
+  end
+
+  function view.focus(_, index)
+    if not view.shown or view.loading then
+      view.pending = index
+      return
+    end
+    select(index)
+  end
+
+  function view.close()
+    picker:close()
+  end
+
+  return view
+end
+
+return M
@@ -0,0 +1,217 @@
+--- Telescope adapter for scopes.nvim
+--- Shows the controller's items in a Telescope picker. Drill-down and go-up
+--- swap the finder in place and retitle the prompt with the breadcrumb.
+
+local config = require("scopes.config")
+
+local M = {}
+
+--- Telescope highlight group for each part role (see Controller:parts()).
+local HL = {
+  icon = "TelescopeResultsSpecialComment",
+  name = "TelescopeResultsIdentifier",
+  error = "DiagnosticError",
+  kind = "TelescopeResultsComment",
+  line = "TelescopeResultsLineNr",
+  drill = "TelescopeResultsClass",
+}
+
+--- Build the display string and highlights for an entry.
+--- @param ctrl scopes.Controller
+--- @param node ScopeNode
+--- @return string, table[]
+local function display(ctrl, node)
+  local text = ""
+  local highlights = {}
+  for i, part in ipairs(ctrl:parts(node)) do
+    if i > 1 then
+      text = text .. " "
+    end
+    table.insert(highlights, { { #text, #text + #part.text }, HL[part.role] })
+    text = text .. part.text
+  end
+  return text, highlights
+end
+
+--- Create a finder over `items`.
+--- @param ctrl scopes.Controller
+--- @param items ScopeNode[]
+--- @param buf_name string
+--- @return table
+local function make_finder(ctrl, items, buf_name)
+  local finders = require("telescope.finders")
+  return finders.new_table({
+    results = items,
+    entry_maker = function(node)
+      return {
+        value = node,
+        node = node,
+        ordinal = ctrl:label(node),
+        display = function()
+          return display(ctrl, node)
+        end,
+        bufnr = ctrl.bufnr,
+        filename = buf_name,
+        lnum = node.range.start_row + 1,
+        col = node.range.start_col,
+      }
+    end,
+  })
+end
+
+--- Wrap a previewer so that every selection change also peeks at the node
+--- in the main window. Telescope calls preview() on each new selection.
+--- @param ctrl scopes.Controller
+--- @param previewer table
+--- @return table
+local function peek_previewer(ctrl, previewer)
+  local preview = previewer.preview
+  function previewer:preview(entry, status)
+    ctrl:preview(entry and entry.node)
+    return preview(self, entry, status)
+  end
+  return previewer
+end
+
+--- Returns true if telescope.nvim is installed.
+--- @return boolean
+function M.available()
+  return (pcall(require, "telescope"))
+end
+
+--- Open the scope picker in Telescope.
+--- @param ctrl scopes.Controller
+--- @return scopes.PickerView|nil
+function M.open(ctrl)
+  local ok = pcall(require, "telescope")
+  if not ok then
+    vim.notify("scopes.nvim: telescope.nvim is required for picker.backend = 'telescope'", vim.log.levels.ERROR)
+    return nil
+  end
+  local pickers = require("telescope.pickers")
+  local actions = require("telescope.actions")
+  local action_state = require("telescope.actions.state")
+  local conf = require("telescope.config").values
+
+  local buf_name = vim.api.nvim_buf_get_name(ctrl.bufnr)
+  local cfg = config.get()
+
+  local view = { items = ctrl:items() }
+  -- Node to select once the finder has (re)populated the results.
+  local pending_focus = nil
+  local picker
+
+  --- Run `fn` on the selected entry's node.
+  --- @param fn fun(node: ScopeNode)
+  local function with_selected(fn)
+    local entry = action_state.get_selected_entry()
+    if entry then
+      fn(entry.node)
+    end
+  end
+
+  picker = pickers.new({
+    layout_config = { width = cfg.picker.width, height = cfg.picker.height },
+    border = cfg.picker.border ~= "none",
+    sorting_strategy = "ascending",
+  }, {
+    prompt_title = ctrl:title(),
+    finder = make_finder(ctrl, view.items, buf_name),
+    sorter = conf.generic_sorter({}),
+    previewer = cfg.picker.preview and peek_previewer(ctrl, conf.qflist_previewer({})) or nil,
+    attach_mappings = function(_, map)
+      actions.select_default:replace(function()
+        with_selected(function(node)
+          ctrl:confirm(node, "current")
+        end)
+      end)
+      actions.close:enhance({
+        post = function()
+          ctrl:closed()
+        end,
+      })
+
+      local function drill()
+        with_selected(function(node)
+          ctrl:drill(node)
+        end)
+      end
+      local function up()
+        ctrl:up()
+      end
+      local function levels()
+        ctrl:toggle_levels()
+      end
+      local function recursive()
+        ctrl:toggle_recursive()
+      end
+      local function split_v()
+        with_selected(function(node)
+          ctrl:confirm(node, "vsplit")
+        end)
+      end
+      local function split_h()
+        with_selected(function(node)
+          ctrl:confirm(node, "hsplit")
+        end)
+      end
+
+      for _, mode in ipairs({ "i", "n" }) do
+        map(mode, cfg.picker.drill_down, drill)
+        map(mode, cfg.picker.go_up, up)
+        map(mode, cfg.picker.levels, levels)
+        map(mode, cfg.picker.recursive, recursive)
+        map(mode, cfg.picker.split_vertical, split_v)
+        map(mode, cfg.picker.split_horizontal, split_h)
+      end
+      return true
+    end,
+  })
+
+  picker:register_completion_callback(function(self)
+    local node = pending_focus
+    if not node then
+      return
+    end
+    pending_focus = nil
+    local index = 0
+    for entry in self.manager:iter() do
+      index = index + 1
+      if entry.node == node then
+        self:set_selection(self:get_row(index))
+        return
+      end
+    end
+  end)
+
+  picker:find()
+
+  function view.show(_, items)
+    view.items = items
+    picker:refresh(make_finder(ctrl, items, buf_name), { reset_prompt = false })
+  end
+
+  function view.set_title(_, title)
+    if picker.prompt_border then
+      picker.prompt_border:change_title(title)
+    end
+  end
+
+  function view.refresh()
+    view:show(view.items)
+  end
+
+  -- The controller focuses right after opening or showing items, so the
+  -- selection is applied when that population completes.
+  function view.focus(_, index)
+    pending_focus = view.items[index]
+  end
+
+  function view.close()
+    actions.close(picker.prompt_bufnr)
+  end
+
+  return view
+end
+
+return M
@@ -0,0 +1,103 @@
+--- Background pre-building of scope trees.
+--- Keeps the tree cache warm so the picker, statusline consumers and motions
+--- rarely pay for a build themselves: trees are built on BufEnter,
+--- BufWritePost and CursorHold, and rebuilt cache.debounce_ms after edits stop.
+
+local config = require("scopes.config")
+local log = require("scopes.log")
+
+local M = {}
+
+local GROUP = "scopes_prebuild"
+
+-- Debounce timers per buffer: { [bufnr] = uv_timer_t }
+local _timers = {}
+
+--- Returns true if `bufnr` is a loaded, normal file buffer.
+--- @param bufnr number
+--- @return boolean
+local function eligible(bufnr)
+  return vim.api.nvim_buf_is_valid(bufnr)
+    and vim.api.nvim_buf_is_loaded(bufnr)
+    and vim.api.nvim_get_option_value("buftype", { buf = bufnr }) == ""
+end
+
+--- Build and cache the tree for `bufnr` now. Large buffers build in the
+--- background (see tree.build_async); a warm cache makes this a lookup.
+--- @param bufnr number
+function M.build_now(bufnr)
+  if not eligible(bufnr) then
+    return
+  end
+  require("scopes.tree").build_async(bufnr)
+end
+
+--- Stop and release the debounce timer for `bufnr`, if any.
+--- @param bufnr number
+function M.cancel(bufnr)
+  local timer = _timers[bufnr]
+  if timer then
+    _timers[bufnr] = nil
+    timer:stop()
+    timer:close()
+  end
+end
+
+--- Build `bufnr` once `delay_ms` (default cache.debounce_ms) pass without
+--- another call for the same buffer.
+--- @param bufnr number
+--- @param delay_ms? number
+function M.schedule(bufnr, delay_ms)
+  delay_ms = delay_ms or config.get().cache.debounce_ms
+  local timer = _timers[bufnr]
+  if not timer then
+    timer = vim.uv.new_timer()
+    _timers[bufnr] = timer
+  end
+  timer:stop()
+  timer:start(
+    delay_ms,
+    0,
+    vim.schedule_wrap(function()
+      M.cancel(bufnr)
+      log.debug("prebuild buf=" .. bufnr)
+      M.build_now(bufnr)
+    end)
+  )
+end
+
+--- Register the pre-build autocmds. Safe to call more than once.
+function M.start()
+  local group = vim.api.nvim_create_augroup(GROUP, { clear = true })
+
+  vim.api.nvim_create_autocmd({ "BufEnter", "BufWritePost", "CursorHold" }, {
+    group = group,
+    callback = function(ev)
+      M.build_now(ev.buf)
+    end,
+  })
+  vim.api.nvim_create_autocmd({ "TextChanged", "TextChangedI" }, {
+    group = group,
+    callback = function(ev)
+      if eligible(ev.buf) then
+        M.schedule(ev.buf)
+      end
+    end,
+  })
+  vim.api.nvim_create_autocmd({ "BufUnload", "BufWipeout" }, {
+    group = group,
+    callback = function(ev)
+      M.cancel(ev.buf)
+    end,
+  })
+end
+
+--- Remove the pre-build autocmds and stop all pending timers.
+function M.stop()
+  pcall(vim.api.nvim_del_augroup_by_name, GROUP)
+  for bufnr in pairs(_timers) do
+    M.cancel(bufnr)
+  end
+end
+
+return M
@@ -0,0 +1,440 @@
+--- Sidebar outline for scopes.nvim
+--- A toggleable split showing the current buffer's ScopeTree as an indented,
+--- foldable outline. Expanding a scope is Navigator:drill_down() and
+--- collapsing one is Navigator:go_up(), so a scope opens in the sidebar
+--- exactly when the picker would drill into it. The deepest scope around the
+--- cursor is highlighted, and the outline is rebuilt after edits and cache
+--- invalidation.
+
+local config = require("scopes.config")
+local icons = require("scopes.icons")
+local log = require("scopes.log")
+local Navigator = require("scopes.navigator")
+local tree_mod = require("scopes.tree")
+
+local M = {}
+
+local NS = vim.api.nvim_create_namespace("scopes_sidebar")
+local CURRENT_NS = vim.api.nvim_create_namespace("scopes_sidebar_current")
+local GROUP = "scopes_sidebar"
+
+--- @class scopes.SidebarState
+--- @field win number  the sidebar window
+--- @field buf number  the sidebar buffer
+--- @field source_buf number  the buffer outlined
+--- @field source_win number  the window last showing source_buf, where <CR> jumps
+--- @field tree ScopeTree|nil
+--- @field nav Navigator|nil  over tree; expand and collapse move it
+--- @field expanded table<string, boolean>  by node path, so it survives rebuilds
+--- @field nodes ScopeNode[]  node shown on each line
+--- @field lines table<ScopeNode, number>  line of each shown node
+--- @field timer uv_timer_t|nil  debounces rebuilds after edits
+
+--- @type scopes.SidebarState|nil
+local _state = nil
+
+--- Key identifying `node` across rebuilds: the kinds and names of its path.
+--- @param node ScopeNode
+--- @return string
+local function path_of(node)
+  local parts = {}
+  local current = node
+  while current and current.parent do
+    table.insert(parts, 1, current.kind .. ":" .. current.name)
+    current = current.parent
+  end
+  return table.concat(parts, "\n")
+end
+
+--- Returns true if the sidebar can outline the buffer in `win`.
+--- @param win number
+--- @return boolean
+local function can_follow(win)
+  if not _state or win == _state.win or not vim.api.nvim_win_is_valid(win) then
+    return false
+  end
+  if vim.api.nvim_win_get_config(win).relative ~= "" then
+    return false
+  end
+  local buftype = vim.api.nvim_get_option_value("buftype", { buf = vim.api.nvim_win_get_buf(win) })
+  return buftype ~= "terminal" and buftype ~= "prompt" and buftype ~= "quickfix"
+end
+
+--- Returns true if `node` is shown expanded.
+--- @param node ScopeNode
+--- @return boolean
+local function is_expanded(node)
+  return _state.expanded[path_of(node)] == true
+end
+
+--- Expand `node`, the way the picker drills into it. No-op for a leaf.
+--- @param node ScopeNode
+--- @return boolean
+local function expand(node)
+  local nav = _state.nav
+  nav:open_at(node.parent or _state.tree.root)
+  if not nav:drill_down(node) then
+    return false
+  end
+  _state.expanded[path_of(node)] = true
+  return true
+end
+
+--- Collapse the level showing `node`'s children, the way the picker goes up
+--- from it. Returns the collapsed scope, or nil at the top level.
+--- @param node ScopeNode
+--- @return ScopeNode|nil
+local function collapse(node)
+  local nav = _state.nav
+  nav:open_at(node)
+  local left = nav:current()
+  if not nav:go_up() then
+    return nil
+  end
+  _state.expanded[path_of(left)] = nil
+  return left
+end
+
+--- Redraw the outline from the tree and the expanded set.
+local function render()
+  local state = _state
+  local show_icons = config.get().display.icons
+  local lines, marks = {}, {}
+  state.nodes = {}
+  state.lines = {}
+
+  local function walk(node, depth)
+    for _, child in ipairs(node:expand()) do
+      local open = child:is_scope() and is_expanded(child)
+      local marker = child:is_scope() and (open and "▾ " or "▸ ") or "  "
+      local text = string.rep("  ", depth) .. marker
+      local row = #lines
+      table.insert(marks, { row, 0, #text, "NonText" })
+      if show_icons then
+        local icon = icons.get_icon(child.kind)
+        table.insert(marks, { row, #text, #text + #icon, "Special" })
+        text = text .. icon .. " "
+      end
+      table.insert(marks, { row, #text, #text + #child.name, child.is_error and "DiagnosticError" or "Identifier" })
+      text = text .. child.name
+      table.insert(lines, text)
+      state.nodes[#lines] = child
+      state.lines[child] = #lines
+      if open then
+        walk(child, depth + 1)
+      end
+    end
+  end
+
+  if state.tree then
+    walk(state.tree.root, 0)
+  end
+  if #lines == 0 then
+    lines = { state.tree and "No scopes" or "No scope tree for this buffer" }
+    marks = { { 0, 0, #lines[1], "Comment" } }
+  end
+
+  vim.bo[state.buf].modifiable = true
+  vim.api.nvim_buf_set_lines(state.buf, 0, -1, false, lines)
+  vim.bo[state.buf].modifiable = false
+  vim.api.nvim_buf_clear_namespace(state.buf, NS, 0, -1)
+  for _, mark in ipairs(marks) do
+    vim.api.nvim_buf_set_extmark(state.buf, NS, mark[1], mark[2], { end_col = mark[3], hl_group = mark[4] })
+  end
+end
+
+--- Highlight the deepest scope around the cursor in the source window,
+--- expanding its ancestors so that it is shown.
+local function follow()
+  local state = _state
+  if not state or not state.tree or not vim.api.nvim_win_is_valid(state.source_win) then
+    return
+  end
+  if vim.api.nvim_win_get_buf(state.source_win) ~= state.source_buf then
+    return
+  end
+  local cursor = vim.api.nvim_win_get_cursor(state.source_win)
+  local scope = tree_mod.find_scope_for_row(state.tree, cursor[1] - 1, cursor[2])
+
+  if scope and not state.lines[scope] then
+    local ancestors = {}
+    local current = scope.parent
+    while current and current.parent do
+      table.insert(ancestors, 1, current)
+      current = current.parent
+    end
+    for _, ancestor in ipairs(ancestors) do
+      expand(ancestor)
+    end
+    render()
+  end
+
+  vim.api.nvim_buf_clear_namespace(state.buf, CURRENT_NS, 0, -1)
+  local line = scope and state.lines[scope]
+  if line then
+    vim.api.nvim_buf_set_extmark(state.buf, CURRENT_NS, line - 1, 0, { line_hl_group = "Visual" })
+    vim.api.nvim_win_set_cursor(state.win, { line, 0 })
+  end
+end
+
+--- Rebuild the tree for the source buffer, then redraw and follow the cursor.
+local function refresh()
+  local state = _state
+  if not state or not vim.api.nvim_buf_is_valid(state.source_buf) then
+    return
+  end
+  local bufnr = state.source_buf
+  tree_mod.build_async(bufnr, {
+    on_done = function(result)
+      if _state ~= state or state.source_buf ~= bufnr or not vim.api.nvim_win_is_valid(state.win) then
+        return
+      end
+      log.debug("sidebar render buf=" .. bufnr)
+      state.tree = result
+      state.nav = result and Navigator.new(result) or nil
+      render()
+      follow()
+    end,
+  })
+end
+
+--- Rebuild once cache.debounce_ms pass without another edit.
+local function schedule_refresh()
+  local state = _state
+  if not state.timer then
+    state.timer = vim.uv.new_timer()
+  end
+  state.timer:stop()
+  state.timer:start(config.get().cache.debounce_ms, 0, vim.schedule_wrap(refresh))
+end
+
+--- Outline the buffer in `win` from now on.
+--- @param win number
+local function set_source(win)
+  local bufnr = vim.api.nvim_win_get_buf(win)
+  _state.source_win = win
+  if bufnr == _state.source_buf then
+    return
+  end
+  _state.source_buf = bufnr
+  _state.tree = nil
+  _state.nav = nil
+  refresh()
+end
+
+--- Returns the node on the sidebar's cursor line, if any.
+--- @return ScopeNode|nil
+local function node_at_cursor()
+  if not _state then
+    return nil
+  end
+  return _state.nodes[vim.api.nvim_win_get_cursor(_state.win)[1]]
+end
+
+--- Move the sidebar cursor to `node`'s line.
+--- @param node ScopeNode
+local function select(node)
+  local line = _state.lines[node]
+  if line then
+    vim.api.nvim_win_set_cursor(_state.win, { line, 0 })
+  end
+end
+
+--- Jump to the node on the sidebar's cursor line in the source window.
+function M.jump()
+  local node = node_at_cursor()
+  if not node or not vim.api.nvim_win_is_valid(_state.source_win) then
+    return
+  end
+  local pos = _state.nav:enter(node)
+  vim.api.nvim_set_current_win(_state.source_win)
+  vim.api.nvim_win_set_cursor(_state.source_win, { pos.row + 1, pos.col })
+end
+
+--- Expand the scope on the sidebar's cursor line, or collapse it if it is
+--- expanded. On a leaf, collapse the scope containing it (like go-up).
+function M.toggle_fold()
+  local node = node_at_cursor()
+  if not node then
+    return
+  end
+  if node:is_scope() and not is_expanded(node) then
+    expand(node)
+    render()
+    select(node)
+    return
+  end
+  local collapsed = collapse(node:is_scope() and node or node.parent)
+  if collapsed then
+    render()
+    select(collapsed)
+  end
+end
+
+--- Expand the scope on the cursor line and move to its first child (drill-down).
+function M.drill_down()
+  local node = node_at_cursor()
+  if not node or not expand(node) then
+    return
+  end
+  render()
+  local first = node:expand()[1]
+  if first then
+    select(first)
+  end
+end
+
+--- Collapse the scope containing the cursor line and move to it (go-up).
+function M.go_up()
+  local node = node_at_cursor()
+  local collapsed = node and node.parent and collapse(node.parent)
+  if collapsed then
+    render()
+    select(collapsed)
+  end
+end
+
+--- Returns true if the sidebar is open.
+--- @return boolean
+function M.is_open()
+  return _state ~= nil and vim.api.nvim_win_is_valid(_state.win)
+end
+
+--- Return the sidebar's window and buffer, or nil when closed.
+--- @return {win: number, buf: number, source_buf: number}|nil
+function M.info()
+  if not M.is_open() then
+    return nil
+  end
+  return { win = _state.win, buf = _state.buf, source_buf = _state.source_buf }
+end
+
+--- Close the sidebar.
+function M.close()
+  local state = _state
+  if not state then
+    return
+  end
+  _state = nil
+  pcall(vim.api.nvim_del_augroup_by_name, GROUP)
+  if state.timer then
+    state.timer:stop()
+    state.timer:close()
+  end
+  if vim.api.nvim_win_is_valid(state.win) then
+    vim.api.nvim_win_close(state.win, true)
+  end
+end
+
+--- Open the sidebar for the current window's buffer. Focus stays where it is.
+function M.open()
+  if M.is_open() then
+    return
+  end
+  M.close()
+  local cfg = config.get().sidebar
+  local source_win = vim.api.nvim_get_current_win()
+
+  local buf = vim.api.nvim_create_buf(false, true)
+  vim.bo[buf].bufhidden = "wipe"
+  vim.bo[buf].filetype = "scopes-sidebar"
+  vim.bo[buf].modifiable = false
+  local win = vim.api.nvim_open_win(buf, false, {
+    split = cfg.position == "left" and "left" or "right",
+    win = -1,
+    width = cfg.width,
+  })
+  for option, value in pairs({
+    number = false,
+    relativenumber = false,
+    signcolumn = "no",
+    foldcolumn = "0",
+    wrap = false,
+    cursorline = true,
+    winfixwidth = true,
+  }) do
+    vim.wo[win][option] = value
+  end
+
+  _state = {
+    win = win,
+    buf = buf,
+    source_buf = vim.api.nvim_win_get_buf(source_win),
+    source_win = source_win,
+    tree = nil,
+    nav = nil,
+    expanded = {},
+    nodes = {},
+    lines = {},
+    timer = nil,
+  }
+
+  local keys = {
+    ["<CR>"] = M.jump,
+    za = M.toggle_fold,
+    [config.get().picker.drill_down] = M.drill_down,
+    [config.get().picker.go_up] = M.go_up,
+    q = M.close,
+  }
+  for key, fn in pairs(keys) do
+    vim.keymap.set("n", key, fn, { buffer = buf, nowait = true })
+  end
+
+  local group = vim.api.nvim_create_augroup(GROUP, { clear = true })
+  vim.api.nvim_create_autocmd({ "BufEnter", "CursorMoved", "CursorMovedI" }, {
+    group = group,
+    callback = function()
+      local current = vim.api.nvim_get_current_win()
+      if not can_follow(current) then
+        return
+      end
+      if vim.api.nvim_win_get_buf(current) ~= _state.source_buf then
+        set_source(current)
+        return
+      end
+      _state.source_win = current
+      follow()
+    end,
+  })
+  vim.api.nvim_create_autocmd({ "TextChanged", "TextChangedI", "BufWritePost" }, {
+    group = group,
+    callback = function(ev)
+      if ev.buf == _state.source_buf then
+        schedule_refresh()
+      end
+    end,
+  })
+  vim.api.nvim_create_autocmd("User", {
+    group = group,
+    pattern = "ScopesInvalidated",
+    callback = function(ev)
+      if ev.data and ev.data.buf == _state.source_buf then
+        refresh()
+      end
+    end,
+  })
+  vim.api.nvim_create_autocmd("WinClosed", {
+    group = group,
+    pattern = tostring(win),
+    callback = function()
+      vim.schedule(function()
+        if _state and _state.win == win then
+          M.close()
+        end
+      end)
+    end,
+  })
+
+  refresh()
+end
+
+--- Open the sidebar, or close it if it is open.
+function M.toggle()
+  if M.is_open() then
+    M.close()
+  else
+    M.open()
+  end
+end
+
+return M
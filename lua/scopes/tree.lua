@@ -5,6 +5,10 @@
 --- @field children ScopeNode[]
 --- @field parent ScopeNode|nil
 --- @field is_error boolean
+--- @field detail string|nil
+--- @field lang string|nil  Language the node was built from (differs from the tree's for injected regions)
+--- @field synthetic "parent"|"self"|nil  Set on the `..` and `.` entries of Navigator:items()
+--- @field target ScopeNode|nil  The scope a synthetic entry stands for
 local ScopeNode = {}
 ScopeNode.__index = ScopeNode
 
@@ -42,7 +46,7 @@ end
 
 --- Create a new ScopeNode.
 --- Validation uses warn-and-continue: always returns a node, emits WARN on bad inputs.
---- @param opts {name: string, kind: string, range: table, children?: ScopeNode[], parent?: ScopeNode, is_error?: boolean}
+--- @param opts {name: string, kind: string, range: table, children?: ScopeNode[], parent?: ScopeNode, is_error?: boolean, detail?: string, lang?: string}
 --- @return ScopeNode
 function ScopeNode.new(opts)
   if type(opts) ~= "table" then
@@ -64,15 +68,62 @@ function ScopeNode.new(opts)
   self.children = opts.children or {}
   self.parent = opts.parent or nil
   self.is_error = opts.is_error or false
+  self.detail = opts.detail
+  self.lang = opts.lang
   return self
 end
 
 --- Returns true if this node can be drilled into (has children).
+--- Answers correctly for lazy nodes whose children are not built yet.
 --- @return boolean
 function ScopeNode:is_scope()
+  if self._expand then
+    return self._has_children
+  end
   return #self.children > 0
 end
 
+--- Build this node's children on demand instead of up front.
+--- `expand` is called at most once, by the first expand(), and must add the
+--- children with add_child(); `has_children` is what is_scope() reports until then.
+--- @param expand fun()
+--- @param has_children boolean
+function ScopeNode:defer(expand, has_children)
+  self._expand = expand
+  self._has_children = has_children
+end
+
+--- Build the children of a lazy node, if not built yet, and return them.
+--- Callers that need a node's children (rather than reading `children` as is)
+--- should go through this.
+--- @return ScopeNode[]
+function ScopeNode:expand()
+  local expand = self._expand
+  if expand then
+    self._expand = nil
+    self._has_children = nil
+    expand()
+  end
+  return self.children
+end
+
+--- Returns true if this node's children have not been built yet.
+--- @return boolean
+function ScopeNode:is_deferred()
+  return self._expand ~= nil
+end
+
+--- Returns true if `range` lies entirely within this node's range (inclusive).
+--- @param range {start_row: number, start_col: number, end_row: number, end_col: number}
+--- @return boolean
+function ScopeNode:contains(range)
+  local r = self.range
+  local starts_inside = r.start_row < range.start_row
+    or (r.start_row == range.start_row and r.start_col <= range.start_col)
+  local ends_inside = r.end_row > range.end_row or (r.end_row == range.end_row and r.end_col >= range.end_col)
+  return starts_inside and ends_inside
+end
+
 --- Add a child node. Sets the child's parent back-reference.
 --- Warns if the child's range is not fully contained within this node's range.
 --- @param child ScopeNode
@@ -134,15 +185,17 @@ end
 
 --- @class ScopeTree
 --- @field root ScopeNode
---- @field source "treesitter"|"lsp"
+--- @field source "treesitter"|"lsp"|"treesitter+lsp"|"indent"
 --- @field bufnr number
 --- @field lang string
+--- @field truncated boolean  true when a build limit stopped the walk and the tree is partial
+--- @field stale boolean  true when this is an earlier, error-free tree served while the buffer does not parse
 local ScopeTree = {}
 ScopeTree.__index = ScopeTree
 
 --- Create a new ScopeTree.
 --- Validation uses warn-and-continue: always returns a tree, emits WARN on bad inputs.
---- @param opts {root: ScopeNode, source: "treesitter"|"lsp", bufnr: number, lang: string}
+--- @param opts {root: ScopeNode, source: "treesitter"|"lsp"|"treesitter+lsp"|"indent", bufnr: number, lang: string, truncated?: boolean}
 --- @return ScopeTree
 function ScopeTree.new(opts)
   if type(opts) ~= "table" then
@@ -152,8 +205,12 @@ function ScopeTree.new(opts)
   if type(opts.root) ~= "table" then
     vim.notify("scopes.nvim: ScopeTree.new(): root must be a table", vim.log.levels.WARN)
   end
-  if opts.source ~= "treesitter" and opts.source ~= "lsp" then
-    vim.notify("scopes.nvim: ScopeTree.new(): source must be 'treesitter' or 'lsp'", vim.log.levels.WARN)
+  local valid_sources = { treesitter = true, lsp = true, ["treesitter+lsp"] = true, indent = true }
+  if not valid_sources[opts.source] then
+    vim.notify(
+      "scopes.nvim: ScopeTree.new(): source must be 'treesitter', 'lsp', 'treesitter+lsp' or 'indent'",
+      vim.log.levels.WARN
+    )
   end
   if type(opts.bufnr) ~= "number" then
     vim.notify("scopes.nvim: ScopeTree.new(): bufnr must be a number", vim.log.levels.WARN)
@@ -167,87 +224,406 @@ function ScopeTree.new(opts)
   self.source = opts.source
   self.bufnr = opts.bufnr
   self.lang = opts.lang
+  self.truncated = opts.truncated or false
+  self.stale = false
   return self
 end
 
-local config = require("scopes.config")
-
--- Per-buffer cache: { [bufnr] = { tree = ScopeTree, timestamp = number } }
-local _cache = {}
+--- Returns true if `range` starts at or before (row, col). A nil col compares rows only.
+--- @param range table
+--- @param row number
+--- @param col? number
+--- @return boolean
+local function starts_at_or_before(range, row, col)
+  return range.start_row < row or (range.start_row == row and (col == nil or range.start_col <= col))
+end
 
---- Check if a row falls within a range (inclusive on both ends).
---- @param range {start_row: number, end_row: number}
+--- Returns true if `range` ends at or after (row, col); end_col is exclusive.
+--- A nil col compares rows only.
+--- @param range table
 --- @param row number
+--- @param col? number
 --- @return boolean
-local function row_in_range(range, row)
-  return row >= range.start_row and row <= range.end_row
+local function ends_at_or_after(range, row, col)
+  return range.end_row > row or (range.end_row == row and (col == nil or range.end_col > col))
 end
 
---- Recursively find the deepest scope node containing `row`.
---- Only descends into children that are scopes (is_scope() == true).
+--- Return the interval index for `node`'s children, (re)building it when the
+--- children changed since it was built (lazy expansion, async fill).
+--- Children are kept in start order, so the index is the list itself plus a
+--- running maximum of end positions, which lets a search skip every earlier
+--- sibling once none of them can reach the position.
+--- @param index table<ScopeNode, table>
 --- @param node ScopeNode
---- @param row number
---- @return ScopeNode|nil
-local function find_deepest_scope_node(node, row)
-  for _, child in ipairs(node.children) do
-    if child:is_scope() and row_in_range(child.range, row) then
-      local deeper = find_deepest_scope_node(child, row)
-      return deeper or child
+--- @return {count: number, max_end: table[]}
+local function children_index(index, node)
+  local children = node:expand()
+  local entry = index[node]
+  if entry and entry.count == #children then
+    return entry
+  end
+  local max_end = {}
+  local best = nil
+  for i, child in ipairs(children) do
+    local r = child.range
+    if not best or r.end_row > best.end_row or (r.end_row == best.end_row and r.end_col > best.end_col) then
+      best = r
     end
+    max_end[i] = best
   end
-  return nil
+  entry = { count = #children, max_end = max_end }
+  index[node] = entry
+  return entry
 end
 
---- Find the deepest scope in `scope_tree` that contains `row`.
---- Returns nil when no scope contains the row; callers fall back to root.
+--- Find the deepest node containing (row, col) and the chain of nodes from
+--- the root down to it. Each level is a binary search over the children's
+--- start positions, so a lookup costs O(depth * log(width)). With `col` nil
+--- only rows are compared, and of several siblings starting on the same row
+--- the first wins. Lazy nodes along the path are expanded.
+--- @param row number  0-indexed
+--- @param col? number  0-indexed byte column
+--- @return ScopeNode node  the root when no child contains the position
+--- @return ScopeNode[] chain  root first, `node` last
+function ScopeTree:node_at(row, col)
+  self._index = self._index or setmetatable({}, { __mode = "k" })
+  local node = self.root
+  local chain = { node }
+  while true do
+    local children = node:expand()
+    local entry = children_index(self._index, node)
+
+    -- Last child starting at or before the position.
+    local lo, hi = 1, #children
+    local last = 0
+    while lo <= hi do
+      local mid = math.floor((lo + hi) / 2)
+      if starts_at_or_before(children[mid].range, row, col) then
+        last = mid
+        lo = mid + 1
+      else
+        hi = mid - 1
+      end
+    end
+
+    -- Siblings normally do not overlap and the first candidate matches; the
+    -- running max end stops the scan as soon as no earlier sibling can.
+    local found = nil
+    for i = last, 1, -1 do
+      if not ends_at_or_after(entry.max_end[i], row, col) then
+        break
+      end
+      if ends_at_or_after(children[i].range, row, col) then
+        found = children[i]
+        -- Without a column, earlier siblings starting on the same row match too.
+        local j = i - 1
+        while col == nil and j >= 1 and children[j].range.start_row == found.range.start_row do
+          if ends_at_or_after(children[j].range, row) then
+            found = children[j]
+          end
+          j = j - 1
+        end
+        break
+      end
+    end
+
+    if not found then
+      return node, chain
+    end
+    node = found
+    table.insert(chain, node)
+  end
+end
+
+local config = require("scopes.config")
+
+-- Tree cache: { [key] = { tree = ScopeTree, bufnr = number, used = number } }, where the
+-- key combines bufnr, b:changedtick, backend, filetype and any explicit lang config,
+-- so an entry is valid exactly as long as the buffer is unchanged. Capped at
+-- cache.max_entries, evicting the least recently used.
+local _cache = {}
+local _cache_size = 0
+local _clock = 0
+local _stats = { hits = 0, misses = 0 }
+
+-- Most recent tree per buffer, whatever its changedtick: the base for incremental builds.
+local _latest = {}
+
+-- Numeric ids for explicit lang config tables, which cannot be part of a string key.
+local _config_ids = setmetatable({}, { __mode = "k" })
+local _next_config_id = 0
+
+--- Find the deepest scope in `scope_tree` that contains `row` (and `col`,
+--- when given). Leaf symbols are skipped: a position inside one resolves to
+--- its enclosing scope.
+--- Returns nil when no scope contains the position; callers fall back to root.
 --- @param scope_tree ScopeTree
 --- @param row number
+--- @param col? number
 --- @return ScopeNode|nil
-local function find_scope_for_row(scope_tree, row)
-  return find_deepest_scope_node(scope_tree.root, row)
+local function find_scope_for_row(scope_tree, row, col)
+  local _, chain = scope_tree:node_at(row, col)
+  for i = #chain, 2, -1 do
+    if chain[i]:is_scope() then
+      return chain[i]
+    end
+  end
+  return nil
+end
+
+-- In-flight async builds: { [bufnr] = scopes.AsyncBuild }
+local _pending = {}
+
+--- Cancel the async build running for `bufnr`, if any.
+--- @param bufnr number
+local function cancel_pending(bufnr)
+  local handle = _pending[bufnr]
+  if handle then
+    _pending[bufnr] = nil
+    handle.cancel()
+  end
+end
+
+--- Remove every cache entry belonging to `bufnr`.
+--- @param bufnr number
+local function evict_buffer(bufnr)
+  for key, entry in pairs(_cache) do
+    if entry.bufnr == bufnr then
+      _cache[key] = nil
+      _cache_size = _cache_size - 1
+    end
+  end
 end
 
---- Evict the cached tree for `bufnr`.
+--- Force the next build() for `bufnr` to rebuild, and cancel any async build.
+--- Edits already change b:changedtick and so miss the cache on their own; this
+--- is for changes the key cannot see (e.g. a language server attaching). The
+--- old tree still seeds an incremental rebuild where the backend supports it.
+--- Fires `User ScopesInvalidated` with `data.buf` so views can rebuild.
 --- @param bufnr number
 local function invalidate(bufnr)
-  _cache[bufnr] = nil
+  cancel_pending(bufnr)
+  evict_buffer(bufnr)
+  vim.api.nvim_exec_autocmds("User", { pattern = "ScopesInvalidated", modeline = false, data = { buf = bufnr } })
 end
 
---- Build a ScopeTree for `bufnr`, dispatching to the configured backend.
---- Returns a cached tree if one exists and was built within cache.debounce_ms.
+--- Drop everything cached for `bufnr`, e.g. when the buffer is unloaded.
 --- @param bufnr number
---- @param opts? { backend?: string, lang_config?: table }
---- @return ScopeTree|nil
-local function build(bufnr, opts)
-  opts = opts or {}
+local function clear(bufnr)
+  cancel_pending(bufnr)
+  evict_buffer(bufnr)
+  _latest[bufnr] = nil
+  local ok, ts = pcall(require, "scopes.backends.treesitter")
+  if ok then
+    ts.forget(bufnr)
+  end
+end
+
+--- Compute the cache key for building `bufnr` with `opts` in its current state.
+--- @param bufnr number
+--- @param opts { backend?: string, lang_config?: table }
+--- @return string
+local function cache_key(bufnr, opts)
+  local config_id = 0
+  if opts.lang_config then
+    if not _config_ids[opts.lang_config] then
+      _next_config_id = _next_config_id + 1
+      _config_ids[opts.lang_config] = _next_config_id
+    end
+    config_id = _config_ids[opts.lang_config]
+  end
+  return table.concat({
+    bufnr,
+    vim.api.nvim_buf_get_changedtick(bufnr),
+    opts.backend or config.get().backend,
+    vim.api.nvim_get_option_value("filetype", { buf = bufnr }),
+    config_id,
+  }, ":")
+end
+
+--- Look up the cache for `bufnr`.
+--- @param bufnr number
+--- @param opts { backend?: string, lang_config?: table }
+--- @return ScopeTree|nil fresh  tree to return as is
+--- @return ScopeTree|nil previous  out-of-date tree to seed an incremental build
+local function lookup(bufnr, opts)
+  if not config.get().cache.enabled then
+    return nil, nil
+  end
+  local entry = _cache[cache_key(bufnr, opts)]
+  if entry then
+    _stats.hits = _stats.hits + 1
+    _clock = _clock + 1
+    entry.used = _clock
+    return entry.tree, nil
+  end
+  _stats.misses = _stats.misses + 1
+  return nil, _latest[bufnr]
+end
+
+--- Store a freshly built tree in the cache, evicting the least recently used
+--- entries beyond cache.max_entries.
+--- @param bufnr number
+--- @param opts { backend?: string, lang_config?: table }
+--- @param scope_tree ScopeTree|nil
+local function store(bufnr, opts, scope_tree)
   local cfg = config.get()
+  if not scope_tree or not cfg.cache.enabled then
+    return
+  end
+  _latest[bufnr] = scope_tree
+
+  local key = cache_key(bufnr, opts)
+  if not _cache[key] then
+    _cache_size = _cache_size + 1
+  end
+  _clock = _clock + 1
+  _cache[key] = { tree = scope_tree, bufnr = bufnr, used = _clock }
 
-  if cfg.cache.enabled then
-    local entry = _cache[bufnr]
-    if entry and (vim.uv.now() - entry.timestamp) < cfg.cache.debounce_ms then
-      return entry.tree
+  while _cache_size > cfg.cache.max_entries do
+    local oldest_key, oldest = nil, nil
+    for k, entry in pairs(_cache) do
+      if not oldest or entry.used < oldest.used then
+        oldest_key, oldest = k, entry
+      end
     end
+    _cache[oldest_key] = nil
+    _cache_size = _cache_size - 1
   end
+end
 
-  local backend = opts.backend or cfg.backend
+--- Return cache counters since startup (or the last reset).
+--- @return {hits: number, misses: number, entries: number}
+local function cache_stats()
+  return { hits = _stats.hits, misses = _stats.misses, entries = _cache_size }
+end
+
+--- Reset the hit/miss counters.
+local function reset_cache_stats()
+  _stats.hits = 0
+  _stats.misses = 0
+end
+
+--- Build a ScopeTree for `bufnr` with the configured backend, bypassing the cache.
+--- @param bufnr number
+--- @param opts { backend?: string, lang_config?: table }
+--- @param previous ScopeTree|nil  seeds an incremental Treesitter build
+--- @return ScopeTree|nil
+local function build_uncached(bufnr, opts, previous)
+  local backend = opts.backend or config.get().backend
   local result = nil
 
-  if backend == "treesitter" or backend == "auto" then
+  if backend == "treesitter" then
     local ok, ts = pcall(require, "scopes.backends.treesitter")
     if ok then
-      result = ts.build(bufnr, opts.lang_config)
+      result = ts.build(bufnr, opts.lang_config, { previous = previous })
+    end
+  elseif backend == "auto" then
+    local ok, auto = pcall(require, "scopes.backends.auto")
+    if ok then
+      result = auto.build(bufnr, { lang_config = opts.lang_config, previous = previous })
+    end
+    if not result then
+      result = require("scopes.backends.indent").build(bufnr)
+    end
+  elseif backend == "lsp" then
+    local ok, lsp = pcall(require, "scopes.backends.lsp")
+    if ok then
+      result = lsp.build(bufnr)
     end
+  elseif backend == "indent" then
+    result = require("scopes.backends.indent").build(bufnr)
   end
+  return result
+end
 
-  if backend == "lsp" then
-    vim.notify("scopes.nvim: LSP backend not yet implemented", vim.log.levels.WARN)
+--- Build a ScopeTree for `bufnr`, dispatching to the configured backend.
+--- With backend = "auto", falls back to the indent backend when neither
+--- Treesitter nor LSP produce a tree.
+--- Returns the cached tree while the buffer's b:changedtick is unchanged.
+--- @param bufnr number
+--- @param opts? { backend?: string, lang_config?: table }
+--- @return ScopeTree|nil
+local function build(bufnr, opts)
+  opts = opts or {}
+  local fresh, previous = lookup(bufnr, opts)
+  if fresh then
+    return fresh
   end
+  local result = build_uncached(bufnr, opts, previous)
+  store(bufnr, opts, result)
+  return result
+end
 
-  if result and cfg.cache.enabled then
-    _cache[bufnr] = { tree = result, timestamp = vim.uv.now() }
+--- Build a ScopeTree for `bufnr` without blocking the UI.
+--- For the "treesitter" and "auto" backends on buffers of at least
+--- async.min_lines lines, the tree is returned at once with an empty root and
+--- filled in chunks (see backends.treesitter.build_async); `on_progress` runs
+--- after each chunk and `on_done` once it is complete (after the LSP merge for
+--- "auto"). Everything else builds synchronously and calls `on_done` before
+--- returning. A running build is cancelled by invalidate() or by another
+--- build_async() for the same buffer.
+--- @param bufnr number
+--- @param opts? { backend?: string, lang_config?: table, on_progress?: fun(tree: ScopeTree), on_done?: fun(tree: ScopeTree|nil) }
+--- @return ScopeTree|nil
+local function build_async(bufnr, opts)
+  opts = opts or {}
+  local cfg = config.get()
+  local backend = opts.backend or cfg.backend
+  local on_done = opts.on_done or function() end
+
+  local fresh, previous = lookup(bufnr, opts)
+  if
+    fresh
+    or not cfg.async.enabled
+    or (backend ~= "treesitter" and backend ~= "auto")
+    or vim.api.nvim_buf_line_count(bufnr) < cfg.async.min_lines
+  then
+    local result = fresh
+    if not result then
+      result = build_uncached(bufnr, opts, previous)
+      store(bufnr, opts, result)
+    end
+    on_done(result)
+    return result
   end
 
-  return result
+  cancel_pending(bufnr)
+  local handle
+  handle = require("scopes.backends.treesitter").build_async(bufnr, opts.lang_config, {
+    previous = previous,
+    silent = backend == "auto",
+    on_progress = opts.on_progress,
+    on_done = function(result)
+      if _pending[bufnr] == handle then
+        _pending[bufnr] = nil
+      end
+      if backend == "auto" then
+        result = require("scopes.backends.auto").enrich(result)
+      end
+      store(bufnr, opts, result)
+      on_done(result)
+    end,
+    on_cancel = function()
+      if _pending[bufnr] == handle then
+        _pending[bufnr] = nil
+      end
+    end,
+  })
+
+  if not handle then
+    local result = nil
+    if backend == "auto" then
+      result = require("scopes.backends.lsp").build(bufnr, { silent = true })
+        or require("scopes.backends.indent").build(bufnr)
+    end
+    store(bufnr, opts, result)
+    on_done(result)
+    return result
+  end
+
+  _pending[bufnr] = handle
+  return handle.tree
 end
 
 return {
@@ -255,5 +631,9 @@ return {
   ScopeTree = ScopeTree,
   find_scope_for_row = find_scope_for_row,
   invalidate = invalidate,
+  clear = clear,
+  cache_stats = cache_stats,
+  reset_cache_stats = reset_cache_stats,
   build = build,
+  build_async = build_async,
 }
@@ -11,3 +11,7 @@ end, { desc = "Open scope picker at cursor position" })
 vim.api.nvim_create_user_command("ScopeBrowse", function()
   require("scopes").open({ root = true })
 end, { desc = "Open scope picker at file root" })
+
+vim.api.nvim_create_user_command("ScopeToggleSidebar", function()
+  require("scopes").toggle_sidebar()
+end, { desc = "Toggle the scope outline sidebar" })
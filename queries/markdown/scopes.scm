@@ -0,0 +1,18 @@
+; Markdown outline for scopes.nvim.
+; Every heading's section is a drillable scope named after the heading text;
+; fenced code blocks are symbols named after their info string language.
+
+(section
+  (atx_heading
+    heading_content: (_) @scope.name)) @scope.module
+
+(section
+  (setext_heading
+    heading_content: (_) @scope.name)) @scope.module
+
+((fenced_code_block) @symbol.block
+  (#set! symbol.name "code"))
+
+(fenced_code_block
+  (info_string
+    (language) @symbol.name)) @symbol.block
@@ -0,0 +1,225 @@
+local auto = require("scopes.backends.auto")
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local ScopeTree = tree_mod.ScopeTree
+local helpers = require("tests.helpers")
+
+local SymbolKind = vim.lsp.protocol.SymbolKind
+
+local function node(name, kind, start_row, end_row, detail)
+  return ScopeNode.new({
+    name = name,
+    kind = kind,
+    range = { start_row = start_row, start_col = 0, end_row = end_row, end_col = 1 },
+    detail = detail,
+  })
+end
+
+--- Treesitter-like tree:
+---   root (0-20)
+---   ├── A  function (1-5)
+---   └── B  function (7-9)
+local function make_ts_tree()
+  local root = node("file", "file", 0, 20)
+  root:add_child(node("A", "function", 1, 5))
+  root:add_child(node("B", "function", 7, 9))
+  return ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+end
+
+--- LSP-like tree:
+---   root (0-20)
+---   ├── A        method   (1-5)  detail "func()"
+---   ├── Wrapper  class    (6-10)
+---   │   └── B    function (7-9)
+---   └── C        variable (12-12)
+local function make_lsp_tree()
+  local root = node("file", "file", 0, 20)
+  root:add_child(node("A", "method", 1, 5, "func()"))
+  local wrapper = node("Wrapper", "class", 6, 10)
+  wrapper:add_child(node("B", "function", 7, 9))
+  root:add_child(wrapper)
+  root:add_child(node("C", "variable", 12, 12))
+  return ScopeTree.new({ root = root, source = "lsp", bufnr = 1, lang = "go" })
+end
+
+describe("backends.auto", function()
+  describe("merge()", function()
+    local merged
+
+    before_each(function()
+      merged = auto.merge(make_ts_tree(), make_lsp_tree())
+    end)
+
+    it("sets source to treesitter+lsp", function()
+      assert.are.equal("treesitter+lsp", merged.source)
+    end)
+
+    it("copies the LSP detail onto a matched node", function()
+      assert.are.equal("func()", helpers.find_by_name(merged.root, "A")[1].detail)
+    end)
+
+    it("refines a generic Treesitter kind with the LSP kind", function()
+      assert.are.equal("method", helpers.find_by_name(merged.root, "A")[1].kind)
+    end)
+
+    it("does not duplicate matched symbols", function()
+      assert.are.equal(1, #helpers.find_by_name(merged.root, "A"))
+      assert.are.equal(1, #helpers.find_by_name(merged.root, "B"))
+    end)
+
+    it("inserts LSP-only symbols in document order", function()
+      assert.are.same({ "A", "Wrapper", "C" }, helpers.child_names(merged.root))
+    end)
+
+    it("moves Treesitter nodes inside an LSP-only container", function()
+      local wrapper = helpers.find_by_name(merged.root, "Wrapper")[1]
+      assert.are.same({ "B" }, helpers.child_names(wrapper))
+      helpers.check_parents(wrapper, merged.root)
+    end)
+
+    it("does not match an unrelated Treesitter node on the same rows", function()
+      local ts_tree = make_ts_tree()
+      ts_tree.root:add_child(node("if", "if", 12, 12))
+      auto.merge(ts_tree, make_lsp_tree())
+      local if_node = helpers.find_by_name(ts_tree.root, "if")[1]
+      assert.are.equal("if", if_node.kind)
+      assert.are.equal(1, #helpers.find_by_name(ts_tree.root, "C"))
+    end)
+
+    it("matches a differently named node of an agreeing kind", function()
+      local ts_tree = make_ts_tree()
+      local lsp_tree = make_lsp_tree()
+      lsp_tree.root.children[1].name = "(*T).A"
+      auto.merge(ts_tree, lsp_tree)
+      local a = helpers.find_by_name(ts_tree.root, "A")[1]
+      assert.are.equal("method", a.kind)
+      assert.are.equal(0, #helpers.find_by_name(ts_tree.root, "(*T).A"))
+    end)
+
+    it("leaves lazy nodes unexpanded", function()
+      local ts_tree = make_ts_tree()
+      local a = ts_tree.root.children[1]
+      local expanded = false
+      a:defer(function()
+        expanded = true
+      end, true)
+      local lsp_tree = make_lsp_tree()
+      lsp_tree.root.children[1]:add_child(node("inner", "variable", 2, 2))
+      auto.merge(ts_tree, lsp_tree)
+      assert.is_false(expanded)
+      assert.is_true(a:is_deferred())
+      assert.are.equal("func()", a.detail)
+      assert.are.equal(0, #helpers.find_by_name(ts_tree.root, "inner"))
+    end)
+
+    it("does not overwrite an unrelated Treesitter kind", function()
+      local ts_tree = make_ts_tree()
+      ts_tree.root.children[2].kind = "block"
+      local lsp_tree = make_lsp_tree()
+      auto.merge(ts_tree, lsp_tree)
+      assert.are.equal("block", helpers.find_by_name(ts_tree.root, "B")[1].kind)
+    end)
+  end)
+
+  describe("build() with Go fixture", function()
+    local bufnr, client_id
+
+    --- Symbols in the shape gopls returns for tests/fixtures/sample.go.
+    local function gopls_symbols()
+      local function range(sl, sc, el, ec)
+        return { start = { line = sl, character = sc }, ["end"] = { line = el, character = ec } }
+      end
+      return {
+        {
+          name = "MyStruct",
+          kind = SymbolKind.Struct,
+          detail = "struct{...}",
+          range = range(14, 5, 17, 1),
+          selectionRange = range(14, 5, 14, 13),
+        },
+        {
+          name = "(*MyStruct).HandleRequest",
+          kind = SymbolKind.Method,
+          detail = "func(action string) error",
+          range = range(32, 0, 52, 1),
+          selectionRange = range(32, 19, 32, 32),
+        },
+        {
+          name = "main",
+          kind = SymbolKind.Package,
+          range = range(0, 0, 0, 12),
+          selectionRange = range(0, 8, 0, 12),
+        },
+      }
+    end
+
+    before_each(function()
+      require("scopes.config").merge({})
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+      vim.api.nvim_buf_set_name(bufnr, vim.fn.tempname() .. ".go")
+    end)
+
+    after_each(function()
+      helpers.stop_fake_lsp(client_id)
+      client_id = nil
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("returns the plain Treesitter tree when no LSP is attached", function()
+      local scope_tree = auto.build(bufnr)
+      assert.is_truthy(scope_tree)
+      assert.are.equal("treesitter", scope_tree.source)
+      assert.are.equal("type", helpers.find_by_name(scope_tree.root, "MyStruct")[1].kind)
+    end)
+
+    it("merges LSP kinds and details into the Treesitter tree", function()
+      client_id = helpers.start_fake_lsp(bufnr, { ["textDocument/documentSymbol"] = gopls_symbols() })
+      local scope_tree = auto.build(bufnr)
+      assert.are.equal("treesitter+lsp", scope_tree.source)
+      local my_struct = helpers.find_by_name(scope_tree.root, "MyStruct")[1]
+      assert.are.equal("struct", my_struct.kind)
+      assert.are.equal("struct{...}", my_struct.detail)
+      local handle = helpers.find_by_name(scope_tree.root, "HandleRequest")[1]
+      assert.are.equal("func(action string) error", handle.detail)
+    end)
+
+    it("adds symbols the lang config missed", function()
+      client_id = helpers.start_fake_lsp(bufnr, { ["textDocument/documentSymbol"] = gopls_symbols() })
+      local scope_tree = auto.build(bufnr)
+      local first = scope_tree.root.children[1]
+      assert.are.equal("main", first.name)
+      assert.are.equal("module", first.kind)
+      assert.are.equal(11, #scope_tree.root.children)
+    end)
+
+    it("keeps treesitter.lazy lazy", function()
+      require("scopes.config").merge({ treesitter = { lazy = true } })
+      local symbols = gopls_symbols()
+      -- The loop variable of HandleRequest's for loop, inside a scope not built yet.
+      local i_range = { start = { line = 37, character = 5 }, ["end"] = { line = 37, character = 6 } }
+      symbols[2].children = {
+        { name = "i", kind = SymbolKind.Variable, range = i_range, selectionRange = i_range },
+      }
+      client_id = helpers.start_fake_lsp(bufnr, { ["textDocument/documentSymbol"] = symbols })
+      local scope_tree = auto.build(bufnr)
+      assert.are.equal("treesitter+lsp", scope_tree.source)
+      local handle = helpers.find_by_name(scope_tree.root, "HandleRequest")[1]
+      assert.are.equal("func(action string) error", handle.detail)
+      assert.is_true(handle:is_deferred())
+      assert.is_true(#handle:expand() > 0)
+      helpers.check_parents(handle, scope_tree.root)
+    end)
+
+    it("returns the Treesitter tree when the LSP is slower than merge_timeout_ms", function()
+      require("scopes.config").merge({ lsp = { merge_timeout_ms = 20 } })
+      client_id = helpers.start_fake_lsp(
+        bufnr,
+        { ["textDocument/documentSymbol"] = gopls_symbols() },
+        { delay_ms = 500 }
+      )
+      local scope_tree = auto.build(bufnr)
+      assert.is_truthy(scope_tree)
+      assert.are.equal("treesitter", scope_tree.source)
+    end)
+  end)
+end)
@@ -0,0 +1,88 @@
+local indent = require("scopes.backends.indent")
+local helpers = require("tests.helpers")
+
+--- Create a scratch buffer with no filetype holding `lines`.
+local function make_plain_buf(lines)
+  local bufnr = vim.api.nvim_create_buf(false, true)
+  vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+  return bufnr
+end
+
+describe("backends.indent", function()
+  local bufnr
+
+  after_each(function()
+    helpers.delete_buf(bufnr)
+  end)
+
+  describe("build() with nested blocks", function()
+    local scope_tree
+
+    before_each(function()
+      bufnr = make_plain_buf({
+        "server",
+        "  listen 80",
+        "  location /",
+        "    root /var/www",
+        "",
+        "    index index.html",
+        "  gzip on",
+        "logging",
+        "  level debug",
+      })
+      scope_tree = indent.build(bufnr)
+    end)
+
+    it("returns a ScopeTree with source set to indent", function()
+      assert.are.equal("indent", scope_tree.source)
+      assert.are.equal(bufnr, scope_tree.bufnr)
+      assert.are.equal("text", scope_tree.lang)
+    end)
+
+    it("puts unindented lines at the top level", function()
+      assert.are.same({ "server", "logging" }, helpers.child_names(scope_tree.root))
+    end)
+
+    it("nests more-indented lines under the line above them", function()
+      local server = scope_tree.root.children[1]
+      assert.are.same({ "listen 80", "location /", "gzip on" }, helpers.child_names(server))
+      local location = server.children[2]
+      assert.are.same({ "root /var/www", "index index.html" }, helpers.child_names(location))
+      assert.is_true(location:is_scope())
+      assert.is_false(server.children[1]:is_scope())
+    end)
+
+    it("extends a block across blank lines to its last child", function()
+      local location = scope_tree.root.children[1].children[2]
+      assert.are.same({ start_row = 2, start_col = 2, end_row = 5, end_col = 20 }, location.range)
+    end)
+
+    it("parent back-references are correct", function()
+      for _, child in ipairs(scope_tree.root.children) do
+        helpers.check_parents(child, scope_tree.root)
+      end
+    end)
+
+    it("all nodes have valid ranges", function()
+      helpers.check_ranges(scope_tree.root)
+    end)
+  end)
+
+  describe("build() with tabs", function()
+    it("expands tabs using the buffer's tabstop", function()
+      bufnr = make_plain_buf({ "a", "\tb", "        c" })
+      vim.api.nvim_set_option_value("tabstop", 8, { buf = bufnr })
+      local scope_tree = indent.build(bufnr)
+      local a = scope_tree.root.children[1]
+      assert.are.same({ "b", "c" }, helpers.child_names(a))
+    end)
+  end)
+
+  describe("build() with an empty buffer", function()
+    it("returns a root with no children", function()
+      bufnr = make_plain_buf({ "" })
+      local scope_tree = indent.build(bufnr)
+      assert.are.equal(0, #scope_tree.root.children)
+    end)
+  end)
+end)
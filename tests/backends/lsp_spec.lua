@@ -0,0 +1,241 @@
+local lsp_backend = require("scopes.backends.lsp")
+local helpers = require("tests.helpers")
+
+local SymbolKind = vim.lsp.protocol.SymbolKind
+
+local LINES = {
+  "type Server struct {",
+  "  addr string",
+  "}",
+  "",
+  "func (s *Server) Start() error {",
+  "  return nil",
+  "}",
+}
+
+--- Build an LSP Range table.
+local function lsp_range(sl, sc, el, ec)
+  return { start = { line = sl, character = sc }, ["end"] = { line = el, character = ec } }
+end
+
+--- Canned hierarchical DocumentSymbol[] for LINES, deliberately out of document order.
+local function document_symbols()
+  return {
+    {
+      name = "Start",
+      kind = SymbolKind.Method,
+      detail = "func() error",
+      range = lsp_range(4, 0, 6, 1),
+      selectionRange = lsp_range(4, 17, 4, 22),
+    },
+    {
+      name = "Server",
+      kind = SymbolKind.Struct,
+      detail = "struct{...}",
+      range = lsp_range(0, 0, 2, 1),
+      selectionRange = lsp_range(0, 5, 0, 11),
+      children = {
+        {
+          name = "addr",
+          kind = SymbolKind.Field,
+          detail = "string",
+          range = lsp_range(1, 2, 1, 13),
+          selectionRange = lsp_range(1, 2, 1, 6),
+        },
+      },
+    },
+  }
+end
+
+--- Canned flat SymbolInformation[] for LINES.
+local function symbol_information(uri)
+  return {
+    { name = "Server", kind = SymbolKind.Struct, location = { uri = uri, range = lsp_range(0, 0, 2, 1) } },
+    {
+      name = "addr",
+      kind = SymbolKind.Field,
+      containerName = "Server",
+      location = { uri = uri, range = lsp_range(1, 2, 1, 13) },
+    },
+    { name = "Start", kind = SymbolKind.Method, location = { uri = uri, range = lsp_range(4, 0, 6, 1) } },
+  }
+end
+
+--- Create a named, file-like buffer holding LINES so LSP clients can attach.
+local function make_lsp_buf()
+  local bufnr = vim.api.nvim_create_buf(true, false)
+  vim.api.nvim_buf_set_name(bufnr, vim.fn.tempname() .. ".go")
+  vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, LINES)
+  return bufnr
+end
+
+describe("backends.lsp", function()
+  local bufnr
+
+  before_each(function()
+    require("scopes.config").merge({})
+    bufnr = make_lsp_buf()
+  end)
+
+  after_each(function()
+    helpers.delete_buf(bufnr)
+  end)
+
+  describe("convert() with DocumentSymbol[]", function()
+    local scope_tree
+
+    before_each(function()
+      scope_tree = lsp_backend.convert(document_symbols(), bufnr, "utf-16")
+    end)
+
+    it("returns a ScopeTree with source set to lsp", function()
+      assert.are.equal("lsp", scope_tree.source)
+      assert.are.equal(bufnr, scope_tree.bufnr)
+    end)
+
+    it("orders top-level symbols by start position", function()
+      assert.are.same({ "Server", "Start" }, helpers.child_names(scope_tree.root))
+    end)
+
+    it("maps SymbolKind to kind strings", function()
+      assert.are.equal("struct", helpers.find_by_name(scope_tree.root, "Server")[1].kind)
+      assert.are.equal("method", helpers.find_by_name(scope_tree.root, "Start")[1].kind)
+      assert.are.equal("variable", helpers.find_by_name(scope_tree.root, "addr")[1].kind)
+    end)
+
+    it("nests DocumentSymbol children under their parent", function()
+      local server = helpers.find_by_name(scope_tree.root, "Server")[1]
+      assert.are.same({ "addr" }, helpers.child_names(server))
+      assert.is_true(server:is_scope())
+    end)
+
+    it("keeps the LSP detail string", function()
+      assert.are.equal("func() error", helpers.find_by_name(scope_tree.root, "Start")[1].detail)
+    end)
+
+    it("uses the full symbol range, not the selection range", function()
+      local start = helpers.find_by_name(scope_tree.root, "Start")[1]
+      assert.are.same({ start_row = 4, start_col = 0, end_row = 6, end_col = 1 }, start.range)
+    end)
+
+    it("parent back-references are correct", function()
+      for _, child in ipairs(scope_tree.root.children) do
+        helpers.check_parents(child, scope_tree.root)
+      end
+    end)
+
+    it("all nodes have valid ranges", function()
+      helpers.check_ranges(scope_tree.root)
+    end)
+  end)
+
+  describe("convert() with SymbolInformation[]", function()
+    it("puts every symbol directly under the root", function()
+      local uri = vim.uri_from_bufnr(bufnr)
+      local scope_tree = lsp_backend.convert(symbol_information(uri), bufnr, "utf-16")
+      assert.are.same({ "Server", "addr", "Start" }, helpers.child_names(scope_tree.root))
+      for _, child in ipairs(scope_tree.root.children) do
+        assert.is_false(child:is_scope())
+      end
+    end)
+
+    it("keeps containerName as detail", function()
+      local uri = vim.uri_from_bufnr(bufnr)
+      local scope_tree = lsp_backend.convert(symbol_information(uri), bufnr, "utf-16")
+      assert.are.equal("Server", helpers.find_by_name(scope_tree.root, "addr")[1].detail)
+    end)
+  end)
+
+  describe("convert() column encodings", function()
+    --- Convert one symbol spanning (row 1, `sc`) to (row 1, `ec`) on a line with
+    --- two 2-byte characters, and return its range.
+    local function converted(sc, ec, encoding)
+      vim.api.nvim_buf_set_lines(bufnr, 1, 2, false, { "  ünï string" })
+      local symbols = { { name = "ünï", kind = SymbolKind.Field, range = lsp_range(1, sc, 1, ec) } }
+      symbols[1].selectionRange = symbols[1].range
+      return lsp_backend.convert(symbols, bufnr, encoding).root.children[1].range
+    end
+
+    it("converts UTF-16 offsets to byte columns", function()
+      local range = converted(2, 12, "utf-16")
+      assert.are.equal(2, range.start_col)
+      assert.are.equal(14, range.end_col)
+    end)
+
+    it("converts UTF-32 offsets to byte columns", function()
+      assert.are.equal(14, converted(2, 12, "utf-32").end_col)
+    end)
+
+    it("keeps UTF-8 offsets as they are", function()
+      assert.are.equal(12, converted(2, 12, "utf-8").end_col)
+    end)
+
+    it("clamps offsets past the end of the line", function()
+      assert.are.equal(14, converted(2, 40, "utf-16").end_col)
+    end)
+  end)
+
+  describe("convert() with an empty result", function()
+    it("returns a root with no children", function()
+      local scope_tree = lsp_backend.convert({}, bufnr, "utf-16")
+      assert.are.equal(0, #scope_tree.root.children)
+    end)
+  end)
+
+  describe("build() against a fake client", function()
+    local client_id
+
+    after_each(function()
+      helpers.stop_fake_lsp(client_id)
+      client_id = nil
+    end)
+
+    it("builds a tree from a hierarchical response", function()
+      client_id = helpers.start_fake_lsp(bufnr, { ["textDocument/documentSymbol"] = document_symbols() })
+      local scope_tree = lsp_backend.build(bufnr)
+      assert.is_truthy(scope_tree)
+      assert.are.equal("lsp", scope_tree.source)
+      assert.are.same({ "Server", "Start" }, helpers.child_names(scope_tree.root))
+    end)
+
+    it("builds a flat tree from a SymbolInformation response", function()
+      local uri = vim.uri_from_bufnr(bufnr)
+      client_id = helpers.start_fake_lsp(bufnr, { ["textDocument/documentSymbol"] = symbol_information(uri) })
+      local scope_tree = lsp_backend.build(bufnr)
+      assert.is_truthy(scope_tree)
+      assert.are.equal(3, #scope_tree.root.children)
+    end)
+
+    it("returns nil when the server does not answer within timeout_ms", function()
+      client_id = helpers.start_fake_lsp(
+        bufnr,
+        { ["textDocument/documentSymbol"] = document_symbols() },
+        { delay_ms = 500 }
+      )
+      local warnings, restore = helpers.capture_notify()
+      local scope_tree = lsp_backend.build(bufnr, { timeout_ms = 50 })
+      restore()
+      assert.is_nil(scope_tree)
+      assert.is_true(#warnings > 0)
+    end)
+  end)
+
+  describe("build() without a client", function()
+    it("returns nil and warns", function()
+      local warnings, restore = helpers.capture_notify()
+      local scope_tree = lsp_backend.build(bufnr)
+      restore()
+      assert.is_nil(scope_tree)
+      assert.is_true(#warnings > 0)
+      assert.is_truthy(warnings[1]:find("LSP"))
+    end)
+
+    it("stays quiet when opts.silent is set", function()
+      local warnings, restore = helpers.capture_notify()
+      local scope_tree = lsp_backend.build(bufnr, { silent = true })
+      restore()
+      assert.is_nil(scope_tree)
+      assert.are.equal(0, #warnings)
+    end)
+  end)
+end)
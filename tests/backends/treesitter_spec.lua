@@ -214,6 +214,584 @@ describe("backends.treesitter", function()
     end)
   end)
 
+  describe("build() with generic fallback (c, no lang config)", function()
+    local scope_tree
+    local bufnr
+
+    before_each(function()
+      local code = table.concat({
+        "struct point {",
+        "  int x;",
+        "  int y;",
+        "};",
+        "",
+        "int add(int a, int b) {",
+        "  int sum = a + b;",
+        "  return sum;",
+        "}",
+      }, "\n")
+      bufnr = select(2, helpers.parse_code(code, "c"))
+      scope_tree = ts_backend.build(bufnr)
+    end)
+
+    after_each(function()
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("returns a ScopeTree instead of nil", function()
+      assert.is_truthy(scope_tree)
+      assert.are.equal("c", scope_tree.lang)
+    end)
+
+    it("treats named nodes with a body as scopes", function()
+      assert.are.same({ "point", "add" }, helpers.child_names(scope_tree.root))
+      local add = helpers.find_by_name(scope_tree.root, "add")[1]
+      assert.are.equal("function", add.kind)
+      assert.is_true(add:is_scope())
+    end)
+
+    it("lists named declarations inside scopes as symbols", function()
+      local point = helpers.find_by_name(scope_tree.root, "point")[1]
+      assert.are.same({ "x", "y" }, helpers.child_names(point))
+      local add = helpers.find_by_name(scope_tree.root, "add")[1]
+      assert.are.same({ "sum" }, helpers.child_names(add))
+    end)
+
+    it("parent back-references are correct", function()
+      for _, child in ipairs(scope_tree.root.children) do
+        helpers.check_parents(child, scope_tree.root)
+      end
+    end)
+  end)
+
+  describe("build() with a scopes.scm query config", function()
+    local lang_config = require("scopes.lang_config")
+    local bufnr
+
+    after_each(function()
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("uses @scope.*, @symbol.* and name captures", function()
+      local query = vim.treesitter.query.parse(
+        "go",
+        [[
+          (function_declaration name: (identifier) @scope.name) @scope.function
+          ((if_statement) @scope.block (#set! scope.name "if"))
+          (short_var_declaration left: (_) @symbol.name) @symbol.variable
+        ]]
+      )
+      local code = table.concat({
+        "package main",
+        "",
+        "func main() {",
+        "  x := 1",
+        "  if x > 0 {",
+        "    y := 2",
+        "  }",
+        "}",
+      }, "\n")
+      bufnr = select(2, helpers.parse_code(code, "go"))
+      local scope_tree = ts_backend.build(bufnr, lang_config.from_query(query))
+
+      assert.are.same({ "main" }, helpers.child_names(scope_tree.root))
+      local main = scope_tree.root.children[1]
+      assert.are.equal("function", main.kind)
+      assert.are.same({ "x", "if" }, helpers.child_names(main))
+      assert.are.equal("variable", main.children[1].kind)
+      assert.are.equal("block", main.children[2].kind)
+      assert.are.same({ "y" }, helpers.child_names(main.children[2]))
+    end)
+
+    it("builds a heading outline from the bundled markdown query", function()
+      local code = table.concat({
+        "# Intro",
+        "",
+        "Some text.",
+        "",
+        "## Usage",
+        "",
+        "```lua",
+        "print(1)",
+        "```",
+        "",
+        "# API",
+      }, "\n")
+      bufnr = select(2, helpers.parse_code(code, "markdown"))
+      local scope_tree = ts_backend.build(bufnr)
+
+      assert.are.same({ "Intro", "API" }, helpers.child_names(scope_tree.root))
+      local intro = scope_tree.root.children[1]
+      assert.are.same({ "Usage" }, helpers.child_names(intro))
+      assert.are.same({ "lua" }, helpers.child_names(intro.children[1]))
+    end)
+  end)
+
+  describe("build() with injected languages", function()
+    local bufnr
+    local code = table.concat({
+      "# Intro",
+      "",
+      "```lua",
+      "function foo()",
+      "end",
+      "```",
+    }, "\n")
+
+    after_each(function()
+      require("scopes.config").merge({})
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("attaches injected scopes under the host node", function()
+      require("scopes.config").merge({})
+      bufnr = select(2, helpers.parse_code(code, "markdown"))
+      local scope_tree = ts_backend.build(bufnr)
+
+      local intro = scope_tree.root.children[1]
+      local fence = intro.children[1]
+      assert.are.equal("lua", fence.name)
+      assert.are.same({ "foo" }, helpers.child_names(fence))
+      assert.are.equal("function", fence.children[1].kind)
+      helpers.check_parents(intro, scope_tree.root)
+    end)
+
+    it("records the language of every node", function()
+      require("scopes.config").merge({})
+      bufnr = select(2, helpers.parse_code(code, "markdown"))
+      local scope_tree = ts_backend.build(bufnr)
+
+      local intro = scope_tree.root.children[1]
+      assert.are.equal("markdown", scope_tree.root.lang)
+      assert.are.equal("markdown", intro.lang)
+      assert.are.equal("lua", helpers.find_by_name(scope_tree.root, "foo")[1].lang)
+    end)
+
+    it("skips injected languages when treesitter.injections is false", function()
+      require("scopes.config").merge({ treesitter = { injections = false } })
+      bufnr = select(2, helpers.parse_code(code, "markdown"))
+      local scope_tree = ts_backend.build(bufnr)
+
+      local fence = scope_tree.root.children[1].children[1]
+      assert.are.equal(0, #fence.children)
+    end)
+  end)
+
+  describe("build() incrementally", function()
+    local bufnr
+    local code = table.concat({
+      "package main",
+      "",
+      "func a() {",
+      "\tx := 1",
+      "}",
+      "",
+      "func b() {",
+      "\ty := 2",
+      "}",
+      "",
+      "func c() {",
+      "}",
+    }, "\n")
+
+    before_each(function()
+      require("scopes.config").merge({})
+      bufnr = select(2, helpers.parse_code(code, "go"))
+    end)
+
+    after_each(function()
+      require("scopes.config").merge({})
+      helpers.delete_buf(bufnr)
+    end)
+
+    --- Insert a statement at the end of b() and rebuild from `previous`.
+    local function edit_and_rebuild(previous)
+      vim.api.nvim_buf_set_lines(bufnr, 8, 8, false, { "\tz := 3" })
+      return ts_backend.build(bufnr, nil, { previous = previous })
+    end
+
+    it("reuses nodes before and after the edit as the same objects", function()
+      local t1 = ts_backend.build(bufnr)
+      local old_a = helpers.find_by_name(t1.root, "a")[1]
+      local old_c = helpers.find_by_name(t1.root, "c")[1]
+      local t2 = edit_and_rebuild(t1)
+
+      assert.are.equal(old_a, helpers.find_by_name(t2.root, "a")[1])
+      assert.are.equal(old_c, helpers.find_by_name(t2.root, "c")[1])
+    end)
+
+    it("moves reused nodes to their new range", function()
+      local t1 = ts_backend.build(bufnr)
+      local t2 = edit_and_rebuild(t1)
+      local c = helpers.find_by_name(t2.root, "c")[1]
+      assert.are.same({ start_row = 11, start_col = 0, end_row = 12, end_col = 1 }, c.range)
+      helpers.check_ranges(t2.root)
+    end)
+
+    it("rebuilds the subtree containing the edit", function()
+      local t1 = ts_backend.build(bufnr)
+      local old_b = helpers.find_by_name(t1.root, "b")[1]
+      local t2 = edit_and_rebuild(t1)
+      local b = helpers.find_by_name(t2.root, "b")[1]
+
+      assert.are_not.equal(old_b, b)
+      assert.are.same({ "y", "z" }, helpers.child_names(b))
+      assert.are.same({ "a", "b", "c" }, helpers.child_names(t2.root))
+      helpers.check_parents(b, t2.root)
+    end)
+
+    it("picks up a renamed node", function()
+      local t1 = ts_backend.build(bufnr)
+      vim.api.nvim_buf_set_text(bufnr, 2, 5, 2, 6, { "alpha" })
+      local t2 = ts_backend.build(bufnr, nil, { previous = t1 })
+      assert.are.same({ "alpha", "b", "c" }, helpers.child_names(t2.root))
+    end)
+
+    it("builds every node fresh when treesitter.incremental is false", function()
+      require("scopes.config").merge({ treesitter = { incremental = false } })
+      local t1 = ts_backend.build(bufnr)
+      local old_a = helpers.find_by_name(t1.root, "a")[1]
+      local t2 = edit_and_rebuild(t1)
+      assert.are_not.equal(old_a, helpers.find_by_name(t2.root, "a")[1])
+    end)
+  end)
+
+  describe("build() lazily", function()
+    local bufnr
+
+    before_each(function()
+      require("scopes.config").merge({ treesitter = { lazy = true } })
+    end)
+
+    after_each(function()
+      require("scopes.config").merge({})
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("builds only the top level up front", function()
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+      local scope_tree = ts_backend.build(bufnr)
+      local handle = helpers.find_by_name(scope_tree.root, "HandleRequest")[1]
+      assert.is_truthy(handle)
+      assert.are.equal(0, #handle.children)
+      assert.is_true(handle:is_scope())
+    end)
+
+    it("expands to the same children as an eager build", function()
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+      local lazy_tree = ts_backend.build(bufnr)
+      require("scopes.config").merge({})
+      local eager_tree = ts_backend.build(bufnr)
+
+      local lazy_handle = helpers.find_by_name(lazy_tree.root, "HandleRequest")[1]
+      local eager_handle = helpers.find_by_name(eager_tree.root, "HandleRequest")[1]
+      lazy_handle:expand()
+      assert.are.same(helpers.child_names(eager_handle), helpers.child_names(lazy_handle))
+      helpers.check_parents(lazy_handle, lazy_tree.root)
+    end)
+
+    it("reports leaf nodes as non-scopes without expanding them", function()
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+      local scope_tree = ts_backend.build(bufnr)
+      for _, child in ipairs(scope_tree.root.children) do
+        local reported = child:is_scope()
+        assert.are.equal(#child:expand() > 0, reported, child.name)
+      end
+    end)
+
+    it("attaches injected regions when their host expands", function()
+      local code = table.concat({ "# Intro", "", "```lua", "function foo()", "end", "```" }, "\n")
+      bufnr = select(2, helpers.parse_code(code, "markdown"))
+      local scope_tree = ts_backend.build(bufnr)
+      local intro = scope_tree.root.children[1]
+      local fence = intro:expand()[1]
+      assert.is_true(fence:is_scope())
+      fence:expand()
+      assert.are.same({ "foo" }, helpers.child_names(fence))
+    end)
+  end)
+
+  describe("build_async()", function()
+    local bufnr
+
+    before_each(function()
+      require("scopes.config").merge({})
+      bufnr = helpers.make_buf("tests/fixtures/huge_file.go", "go")
+    end)
+
+    after_each(function()
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("fills the tree over several chunks and matches a synchronous build", function()
+      local progress = 0
+      local handle = ts_backend.build_async(bufnr, nil, {
+        chunk_size = 500,
+        on_progress = function()
+          progress = progress + 1
+        end,
+      })
+      assert.is_truthy(handle)
+      assert.are.equal(0, #handle.tree.root.children)
+
+      assert.is_true(vim.wait(10000, function()
+        return handle.done
+      end))
+      assert.is_true(progress > 1)
+      assert.are.same(helpers.child_names(ts_backend.build(bufnr).root), helpers.child_names(handle.tree.root))
+    end)
+
+    it("calls on_done with the finished tree", function()
+      local finished
+      local handle = ts_backend.build_async(bufnr, nil, {
+        on_done = function(tree)
+          finished = tree
+        end,
+      })
+      vim.wait(10000, function()
+        return finished ~= nil
+      end)
+      assert.are.equal(handle.tree, finished)
+    end)
+
+    it("cancels itself when the buffer changes", function()
+      local cancelled = false
+      local handle = ts_backend.build_async(bufnr, nil, {
+        chunk_size = 100,
+        on_cancel = function()
+          cancelled = true
+        end,
+      })
+      vim.wait(10000, function()
+        return #handle.tree.root.children > 0
+      end)
+      vim.api.nvim_buf_set_lines(bufnr, 0, 0, false, { "// edited" })
+
+      assert.is_true(vim.wait(1000, function()
+        return cancelled
+      end))
+      assert.is_true(handle.cancelled)
+      assert.is_false(handle.done)
+    end)
+
+    it("stops when cancel() is called", function()
+      local handle = ts_backend.build_async(bufnr, nil, { chunk_size = 100 })
+      handle.cancel()
+      vim.wait(100)
+      assert.is_true(handle.cancelled)
+      assert.is_false(handle.done)
+    end)
+  end)
+
+  describe("build() with limits", function()
+    local config = require("scopes.config")
+    local bufnr
+
+    before_each(function()
+      bufnr = helpers.make_buf("tests/fixtures/huge_file.go", "go")
+    end)
+
+    after_each(function()
+      config.merge({})
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("is not truncated within the default limits", function()
+      config.merge({})
+      assert.is_false(ts_backend.build(bufnr).truncated)
+    end)
+
+    it("stops at max_lines and keeps the nodes before it", function()
+      config.merge({ treesitter = { max_lines = 100 } })
+      local scope_tree = ts_backend.build(bufnr)
+      assert.is_true(scope_tree.truncated)
+      assert.is_true(#scope_tree.root.children > 0)
+      for _, child in ipairs(scope_tree.root.children) do
+        assert.is_true(child.range.start_row < 100)
+      end
+    end)
+
+    it("stops after max_nodes syntax nodes", function()
+      config.merge({ treesitter = { max_nodes = 50 } })
+      local partial = ts_backend.build(bufnr)
+      assert.is_true(partial.truncated)
+      config.merge({})
+      assert.is_true(#partial.root.children < #ts_backend.build(bufnr).root.children)
+    end)
+
+    it("stops after build_timeout_ms", function()
+      config.merge({ treesitter = { build_timeout_ms = 0.001 } })
+      assert.is_true(ts_backend.build(bufnr).truncated)
+    end)
+
+    it("treats 0 as no limit", function()
+      config.merge({ treesitter = { max_lines = 0, max_nodes = 0, build_timeout_ms = 0 } })
+      assert.is_false(ts_backend.build(bufnr).truncated)
+    end)
+
+    it("applies to async builds", function()
+      config.merge({ treesitter = { max_nodes = 300 } })
+      local handle = ts_backend.build_async(bufnr, nil, { chunk_size = 100 })
+      assert.is_true(vim.wait(10000, function()
+        return handle.done
+      end))
+      assert.is_true(handle.tree.truncated)
+    end)
+  end)
+
+  describe("build() with a failing name_getter", function()
+    local lang_config = require("scopes.lang_config")
+    local bufnr, config
+
+    before_each(function()
+      ts_backend.reset_name_failures()
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+      local node_types = vim.deepcopy(require("scopes.languages.go"))
+      node_types.method_declaration.name_getter = function()
+        error("boom")
+      end
+      config = lang_config.build(node_types)
+    end)
+
+    after_each(function()
+      ts_backend.reset_name_failures()
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("names the node after its type with a [?] marker and keeps the rest", function()
+      local _, restore = helpers.capture_notify()
+      local scope_tree = ts_backend.build(bufnr, config)
+      restore()
+      local names = helpers.child_names(scope_tree.root)
+      assert.is_truthy(vim.tbl_contains(names, "method_declaration [?]"))
+      assert.is_truthy(vim.tbl_contains(names, "main"))
+      -- Children of the failed node are still walked.
+      local method = helpers.find_by_name(scope_tree.root, "method_declaration [?]")[1]
+      assert.is_true(#method.children > 0)
+    end)
+
+    it("reports the failures once, grouped by language and node type", function()
+      local warnings, restore = helpers.capture_notify()
+      ts_backend.build(bufnr, config)
+      ts_backend.build(bufnr, config)
+      restore()
+      assert.are.equal(1, #warnings)
+      assert.is_truthy(warnings[1]:find("go method_declaration", 1, true))
+      assert.is_truthy(warnings[1]:find("boom", 1, true))
+    end)
+
+    it("counts failures per language and node type", function()
+      local _, restore = helpers.capture_notify()
+      ts_backend.build(bufnr, config)
+      restore()
+      local failures = ts_backend.name_failures()
+      assert.are.equal(1, failures.go.method_declaration.count)
+      assert.is_nil(failures.go.function_declaration)
+    end)
+  end)
+
+  describe("build() with syntax errors", function()
+    local config = require("scopes.config")
+    local bufnr
+
+    before_each(function()
+      bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+    end)
+
+    after_each(function()
+      ts_backend.forget(bufnr)
+      config.merge({})
+      helpers.delete_buf(bufnr)
+    end)
+
+    it("names ERROR nodes after their first line of source", function()
+      config.merge({})
+      vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int," })
+      local scope_tree = ts_backend.build(bufnr)
+      local errors = {}
+      local function visit(node)
+        for _, child in ipairs(node.children) do
+          if child.is_error then
+            table.insert(errors, child)
+          end
+          visit(child)
+        end
+      end
+      visit(scope_tree.root)
+      assert.is_true(#errors > 0)
+      for _, node in ipairs(errors) do
+        local line = vim.api.nvim_buf_get_lines(bufnr, node.range.start_row, node.range.start_row + 1, false)[1]
+        assert.are_not.equal("[error]", node.name)
+        assert.is_truthy(line:find(node.name, 1, true))
+      end
+    end)
+
+    describe("with keep_last_good", function()
+      before_each(function()
+        config.merge({ treesitter = { keep_last_good = true } })
+      end)
+
+      it("serves the last good tree, shifted, while the parse has errors", function()
+        local good = ts_backend.build(bufnr)
+        local main_row = helpers.find_by_name(good.root, "main")[1].range.start_row
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int,", "" })
+
+        local result = ts_backend.build(bufnr)
+        assert.are_not.equal(good, result)
+        assert.is_true(result.stale)
+        assert.are.equal(main_row + 2, helpers.find_by_name(result.root, "main")[1].range.start_row)
+        for _, child in ipairs(result.root.children) do
+          assert.is_false(child.is_error)
+        end
+      end)
+
+      it("leaves the remembered tree as it was built", function()
+        local good = ts_backend.build(bufnr)
+        local main = helpers.find_by_name(good.root, "main")[1]
+        local range = vim.deepcopy(main.range)
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int,", "" })
+
+        local result = ts_backend.build(bufnr)
+        assert.is_true(result.stale)
+        assert.is_false(good.stale)
+        assert.are.same(range, main.range)
+        helpers.check_parents(result.root)
+      end)
+
+      it("does not remember a truncated tree", function()
+        config.merge({ treesitter = { keep_last_good = true, max_nodes = 5 } })
+        assert.is_true(ts_backend.build(bufnr).truncated)
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int," })
+        assert.is_false(ts_backend.build(bufnr).stale)
+      end)
+
+      it("builds a fresh tree once the parse is clean again", function()
+        local good = ts_backend.build(bufnr)
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int," })
+        ts_backend.build(bufnr)
+        vim.api.nvim_buf_set_lines(bufnr, 18, 19, false, {})
+
+        local result = ts_backend.build(bufnr)
+        assert.are_not.equal(good, result)
+        assert.is_false(result.stale)
+        assert.are.same(helpers.child_names(good.root), helpers.child_names(result.root))
+      end)
+
+      it("builds the erroring tree when there is no good tree yet", function()
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int," })
+        local result = ts_backend.build(bufnr)
+        assert.is_false(result.stale)
+      end)
+
+      it("is ignored in lazy mode", function()
+        config.merge({ treesitter = { keep_last_good = true, lazy = true } })
+        local first = ts_backend.build(bufnr)
+        vim.api.nvim_buf_set_lines(bufnr, 18, 18, false, { "func broken(a int," })
+        assert.are_not.equal(first, ts_backend.build(bufnr))
+      end)
+    end)
+  end)
+
   describe("build() error handling", function()
     it("returns nil for buffer with no treesitter parser", function()
       local bufnr = vim.api.nvim_create_buf(false, true)
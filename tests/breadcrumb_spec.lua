@@ -0,0 +1,167 @@
+--- Tests for lua/scopes/breadcrumb.lua
+--- Uses the Go fixture with the Treesitter backend.
+
+local helpers = require("tests.helpers")
+local config = require("scopes.config")
+local tree_mod = require("scopes.tree")
+local breadcrumb = require("scopes.breadcrumb")
+
+describe("breadcrumb", function()
+  local bufnr, win
+
+  --- Evaluate the breadcrumb and wait for the tree build it schedules.
+  local function wait_for_tree()
+    assert.is_true(vim.wait(1000, function()
+      return #breadcrumb.nodes() > 0
+    end))
+  end
+
+  before_each(function()
+    config.merge({ backend = "treesitter" })
+    bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    breadcrumb.clear()
+    wait_for_tree()
+  end)
+
+  after_each(function()
+    breadcrumb.disable_winbar()
+    breadcrumb.clear()
+    tree_mod.clear(bufnr)
+    helpers.delete_buf(bufnr)
+    config.merge({})
+  end)
+
+  --- Return the breadcrumb's segment names with the cursor on `line` (1-indexed).
+  local function names_at(line)
+    vim.api.nvim_win_set_cursor(win, { line, 0 })
+    return vim.tbl_map(function(node)
+      return node.name
+    end, breadcrumb.nodes())
+  end
+
+  describe("get", function()
+    it("is the path from the file root to the cursor's scope", function()
+      -- m.Count++ inside HandleRequest's for loop
+      vim.api.nvim_win_set_cursor(win, { 45, 0 })
+      local path = require("scopes").breadcrumb()
+      assert.is_truthy(path:find(" > HandleRequest > ", 1, true))
+    end)
+
+    it("is only the root outside every scope", function()
+      assert.are.same({ tree_mod.build(bufnr).root.name }, names_at(2))
+    end)
+
+    it("uses breadcrumb.separator", function()
+      config.merge({ backend = "treesitter", breadcrumb = { separator = " / " } })
+      vim.api.nvim_win_set_cursor(win, { 80, 0 })
+      assert.is_truthy(breadcrumb.get():find(" / main", 1, true))
+    end)
+
+    it("accepts an explicit window", function()
+      vim.api.nvim_win_set_cursor(win, { 80, 0 })
+      assert.are.equal(breadcrumb.get(), breadcrumb.get({ winid = win }))
+      assert.are.equal(breadcrumb.get(), breadcrumb.get({ bufnr = bufnr }))
+    end)
+
+    it("is empty for a buffer not shown in any window", function()
+      local hidden = helpers.make_buf("tests/fixtures/sample.go", "go")
+      assert.are.equal("", breadcrumb.get({ bufnr = hidden }))
+      helpers.delete_buf(hidden)
+    end)
+  end)
+
+  describe("cache", function()
+    it("is reused while changedtick and row are unchanged", function()
+      local first = names_at(45)
+      tree_mod.reset_cache_stats()
+      vim.api.nvim_win_set_cursor(win, { 45, 5 })
+      assert.are.same(first, vim.tbl_map(function(node)
+        return node.name
+      end, breadcrumb.nodes()))
+      local stats = tree_mod.cache_stats()
+      assert.are.equal(0, stats.hits + stats.misses)
+    end)
+
+    it("follows the cursor to another row", function()
+      local in_method = names_at(45)
+      local in_main = names_at(80)
+      assert.are_not.same(in_method, in_main)
+      assert.are.equal("main", in_main[#in_main])
+    end)
+
+    it("follows edits once the scheduled rebuild has run", function()
+      local before = names_at(80)
+      vim.api.nvim_buf_set_lines(bufnr, 78, 79, false, { "func renamed() {" })
+      assert.are.same(before, names_at(80), "the last path is kept meanwhile")
+      assert.is_true(vim.wait(1000, function()
+        local names = names_at(80)
+        return names[#names] == "renamed"
+      end))
+    end)
+
+    it("rebuilds once for a burst of edits", function()
+      config.merge({ backend = "treesitter", cache = { debounce_ms = 100 } })
+      tree_mod.reset_cache_stats()
+      for i = 1, 3 do
+        vim.api.nvim_buf_set_lines(bufnr, 78, 79, false, { "func renamed" .. i .. "() {" })
+        names_at(80)
+        vim.wait(20, function()
+          return false
+        end)
+      end
+      assert.are.equal(0, tree_mod.cache_stats().misses)
+      assert.is_true(vim.wait(1000, function()
+        local names = names_at(80)
+        return names[#names] == "renamed3"
+      end))
+      assert.are.equal(1, tree_mod.cache_stats().misses)
+    end)
+
+    it("does not build while the breadcrumb is evaluated", function()
+      breadcrumb.clear()
+      tree_mod.clear(bufnr)
+      tree_mod.reset_cache_stats()
+      assert.are.same({}, breadcrumb.nodes())
+      local stats = tree_mod.cache_stats()
+      assert.are.equal(0, stats.hits + stats.misses)
+      wait_for_tree()
+      assert.are.equal(1, tree_mod.cache_stats().misses)
+    end)
+  end)
+
+  describe("winbar", function()
+    it("wraps each segment in a click region for its depth", function()
+      vim.api.nvim_win_set_cursor(win, { 80, 0 })
+      local bar = breadcrumb.winbar()
+      assert.is_truthy(bar:find("%1@v:lua.__scopes_breadcrumb_click@", 1, true))
+      assert.is_truthy(bar:find("%2@v:lua.__scopes_breadcrumb_click@main%X", 1, true))
+    end)
+
+    it("escapes % in names", function()
+      vim.api.nvim_win_set_cursor(win, { 80, 0 })
+      local nodes = breadcrumb.nodes()
+      nodes[#nodes].name = "50%"
+      assert.is_truthy(breadcrumb.winbar():find("50%%", 1, true))
+    end)
+
+    it("enable_winbar() sets the winbar of file windows only", function()
+      local file_buf = vim.api.nvim_create_buf(true, false)
+      vim.api.nvim_win_set_buf(win, file_buf)
+      breadcrumb.enable_winbar()
+      assert.is_truthy(vim.wo[win].winbar:find("scopes.breadcrumb", 1, true))
+      -- Set for the window only: windows split off later do not inherit it.
+      assert.are.equal("", vim.api.nvim_get_option_value("winbar", { scope = "global" }))
+      breadcrumb.disable_winbar()
+      assert.are.equal("", vim.wo[win].winbar)
+      vim.api.nvim_win_set_buf(win, bufnr)
+      vim.api.nvim_buf_delete(file_buf, { force = true })
+    end)
+
+    it("enable_winbar() leaves scratch windows alone", function()
+      breadcrumb.enable_winbar()
+      assert.are.equal("", vim.wo[win].winbar)
+    end)
+  end)
+end)
@@ -31,6 +31,12 @@ describe("scopes.config", function()
       assert.are.equal("<C-v>", config.defaults.picker.split_vertical)
     end)
 
+    it("has levels, recursive and nav_entries defaults", function()
+      assert.are.equal("<C-b>", config.defaults.picker.levels)
+      assert.are.equal("<C-r>", config.defaults.picker.recursive)
+      assert.is_false(config.defaults.picker.nav_entries)
+    end)
+
     it("has split_horizontal default", function()
       assert.are.equal("<C-s>", config.defaults.picker.split_horizontal)
     end)
@@ -41,14 +47,62 @@ describe("scopes.config", function()
       assert.is_true(config.defaults.display.breadcrumb)
     end)
 
+    it("has sidebar defaults", function()
+      assert.are.equal(35, config.defaults.sidebar.width)
+      assert.are.equal("right", config.defaults.sidebar.position)
+    end)
+
+    it("has breadcrumb defaults", function()
+      assert.is_false(config.defaults.breadcrumb.winbar)
+      assert.are.equal(" > ", config.defaults.breadcrumb.separator)
+    end)
+
     it("has cache defaults", function()
       assert.is_true(config.defaults.cache.enabled)
       assert.are.equal(300, config.defaults.cache.debounce_ms)
+      assert.are.equal(20, config.defaults.cache.max_entries)
+    end)
+
+    it("has lsp defaults", function()
+      assert.are.equal(1000, config.defaults.lsp.timeout_ms)
+      assert.are.equal(200, config.defaults.lsp.merge_timeout_ms)
     end)
 
     it("has empty treesitter scope_types by default", function()
       assert.are.same({}, config.defaults.treesitter.scope_types)
     end)
+
+    it("walks injected languages by default", function()
+      assert.is_true(config.defaults.treesitter.injections)
+    end)
+
+    it("rebuilds incrementally by default", function()
+      assert.is_true(config.defaults.treesitter.incremental)
+    end)
+
+    it("builds the whole tree up front by default", function()
+      assert.is_false(config.defaults.treesitter.lazy)
+    end)
+
+    it("does not keep the last good tree by default", function()
+      assert.is_false(config.defaults.treesitter.keep_last_good)
+    end)
+
+    it("limits treesitter builds by default", function()
+      assert.are.equal(30000, config.defaults.treesitter.max_lines)
+      assert.are.equal(200000, config.defaults.treesitter.max_nodes)
+      assert.are.equal(2000, config.defaults.treesitter.build_timeout_ms)
+    end)
+
+    it("does not pre-build trees by default", function()
+      assert.is_false(config.defaults.prebuild.enabled)
+    end)
+
+    it("builds large buffers asynchronously by default", function()
+      assert.is_true(config.defaults.async.enabled)
+      assert.are.equal(5000, config.defaults.async.min_lines)
+      assert.are.equal(2000, config.defaults.async.chunk_size)
+    end)
   end)
 
   describe("get", function()
@@ -0,0 +1,575 @@
+--- Tests for lua/scopes/controller.lua
+--- Uses a fake adapter that records the calls the controller makes on its view.
+--- Trees are built by hand; the buffer only needs enough lines to jump into.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local ScopeTree = tree_mod.ScopeTree
+local Navigator = require("scopes.navigator")
+local Controller = require("scopes.controller")
+local config = require("scopes.config")
+
+--- Build a small test tree:
+---
+---   root "sample.go" (rows 0-99)
+---   ├── HandleRequest  function (rows 5-30)
+---   │   ├── req        variable (row 6)      ← leaf
+---   │   └── Validate   function (rows 10-20) ← scope
+---   │       └── err    variable (row 11)     ← leaf
+---   └── main           function (rows 40-60) ← scope
+---       └── x          variable (row 41)     ← leaf
+---
+--- @param bufnr number
+--- @param truncated? boolean
+--- @return ScopeTree, table
+local function make_test_tree(bufnr, truncated)
+  local function node(name, kind, start_row, start_col, end_row, end_col)
+    return ScopeNode.new({
+      name = name,
+      kind = kind,
+      range = { start_row = start_row, start_col = start_col, end_row = end_row, end_col = end_col },
+    })
+  end
+  local root = node("sample.go", "module", 0, 0, 99, 0)
+  local handle = node("HandleRequest", "function", 5, 0, 30, 1)
+  local req = node("req", "variable", 6, 2, 6, 10)
+  local validate = node("Validate", "function", 10, 2, 20, 3)
+  local err = node("err", "variable", 11, 4, 11, 7)
+  local main_fn = node("main", "function", 40, 0, 60, 1)
+  local x = node("x", "variable", 41, 2, 41, 3)
+  root:add_child(handle)
+  root:add_child(main_fn)
+  handle:add_child(req)
+  handle:add_child(validate)
+  validate:add_child(err)
+  main_fn:add_child(x)
+
+  local scope_tree = ScopeTree.new({
+    root = root,
+    source = "treesitter",
+    bufnr = bufnr,
+    lang = "go",
+    truncated = truncated,
+  })
+  return scope_tree,
+    { root = root, handle = handle, req = req, validate = validate, err = err, main = main_fn, x = x }
+end
+
+--- A view that records every call as { method, arg }.
+--- @return scopes.PickerView, table[]
+local function make_view()
+  local calls = {}
+  local view = {}
+  for _, method in ipairs({ "show", "set_title", "refresh", "focus", "close" }) do
+    view[method] = function(_, arg)
+      table.insert(calls, { method, arg })
+    end
+  end
+  return view, calls
+end
+
+--- Return the args of all calls to `method`.
+local function args_of(calls, method)
+  local args = {}
+  for _, call in ipairs(calls) do
+    if call[1] == method then
+      table.insert(args, call[2])
+    end
+  end
+  return args
+end
+
+--- Return how many times `method` was called.
+local function count_of(calls, method)
+  local count = 0
+  for _, call in ipairs(calls) do
+    if call[1] == method then
+      count = count + 1
+    end
+  end
+  return count
+end
+
+describe("Controller", function()
+  local bufnr, win, scope_tree, nodes, nav, ctrl, view, calls
+
+  before_each(function()
+    config.merge({})
+    bufnr = vim.api.nvim_create_buf(false, true)
+    local lines = {}
+    for i = 1, 100 do
+      lines[i] = string.rep(" ", 8) .. "line " .. i
+    end
+    vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 50, 3 })
+
+    scope_tree, nodes = make_test_tree(bufnr)
+    nav = Navigator.new(scope_tree)
+    ctrl = Controller.new(nav, bufnr)
+    view, calls = make_view()
+  end)
+
+  after_each(function()
+    while #vim.api.nvim_list_wins() > 1 do
+      vim.api.nvim_win_close(vim.api.nvim_list_wins()[2], true)
+    end
+    vim.api.nvim_buf_delete(bufnr, { force = true })
+  end)
+
+  local function attach()
+    assert.is_true(ctrl:attach({
+      open = function()
+        return view
+      end,
+    }))
+  end
+
+  describe("title", function()
+    it("is the breadcrumb for a complete tree", function()
+      assert.are.equal("sample.go", ctrl:title())
+    end)
+
+    it("flags a truncated tree", function()
+      local truncated = make_test_tree(bufnr, true)
+      assert.are.equal("sample.go [truncated]", Controller.new(Navigator.new(truncated), bufnr):title())
+    end)
+
+    it("follows drill-down", function()
+      attach()
+      ctrl:drill(nodes.handle)
+      assert.are.same({ "sample.go > HandleRequest" }, args_of(calls, "set_title"))
+    end)
+  end)
+
+  describe("parts", function()
+    local function roles(parts)
+      return vim.tbl_map(function(part)
+        return part.role
+      end, parts)
+    end
+
+    it("labels a scope's icon, name, kind, line and drill marker", function()
+      assert.are.same({ "icon", "name", "kind", "line", "drill" }, roles(ctrl:parts(nodes.handle)))
+    end)
+
+    it("has no drill marker for a leaf", function()
+      assert.are.same({ "icon", "name", "kind", "line" }, roles(ctrl:parts(nodes.req)))
+    end)
+
+    it("omits the icon when display.icons is off", function()
+      config.merge({ display = { icons = false } })
+      assert.are.same({ "name", "kind", "line" }, roles(ctrl:parts(nodes.req)))
+    end)
+
+    it("shows the kind in brackets and the 1-indexed line", function()
+      local parts = ctrl:parts(nodes.req)
+      assert.are.equal("[variable]", parts[3].text)
+      assert.are.equal(":7", parts[4].text)
+    end)
+
+    it("marks the name of an ERROR node", function()
+      local broken = ScopeNode.new({
+        name = "broken",
+        kind = "error",
+        range = { start_row = 0, start_col = 0, end_row = 1, end_col = 0 },
+        is_error = true,
+      })
+      assert.are.equal("error", ctrl:parts(broken)[2].role)
+    end)
+  end)
+
+  describe("attach", function()
+    it("returns false when the adapter cannot open", function()
+      assert.is_false(ctrl:attach({
+        open = function()
+          return nil
+        end,
+      }))
+      assert.is_nil(ctrl.view)
+    end)
+
+    it("focuses the item under the cursor", function()
+      nav = Navigator.new(scope_tree, { cursor_row = 11, cursor_col = 5 })
+      ctrl = Controller.new(nav, bufnr)
+      attach()
+      -- The cursor is in err, listed first in Validate's items.
+      assert.are.same({ 1 }, args_of(calls, "focus"))
+    end)
+
+    it("does not focus anything when opened at the root", function()
+      attach()
+      assert.are.same({}, args_of(calls, "focus"))
+    end)
+  end)
+
+  describe("drill", function()
+    it("shows the children of a scope", function()
+      attach()
+      assert.is_true(ctrl:drill(nodes.handle))
+      assert.are.same({ { nodes.req, nodes.validate } }, args_of(calls, "show"))
+    end)
+
+    it("does nothing for a leaf", function()
+      attach()
+      ctrl:drill(nodes.handle)
+      assert.is_false(ctrl:drill(nodes.req))
+      assert.are.equal(nodes.handle, nav:current())
+    end)
+  end)
+
+  describe("up", function()
+    it("shows the parent level and focuses the scope just left", function()
+      attach()
+      ctrl:drill(nodes.handle)
+      ctrl:drill(nodes.validate)
+      for i = #calls, 1, -1 do
+        calls[i] = nil
+      end
+      assert.is_true(ctrl:up())
+      assert.are.same({ { nodes.req, nodes.validate } }, args_of(calls, "show"))
+      assert.are.same({ 2 }, args_of(calls, "focus"))
+    end)
+
+    it("returns false at the root", function()
+      attach()
+      assert.is_false(ctrl:up())
+      assert.are.same({}, args_of(calls, "show"))
+    end)
+  end)
+
+  describe("confirm", function()
+    it("closes the view and jumps to the node", function()
+      attach()
+      ctrl:confirm(nodes.main)
+      assert.are.equal(1, count_of(calls, "close"))
+      assert.is_true(ctrl:is_closed())
+      assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("opens a vertical split at the node", function()
+      attach()
+      ctrl:confirm(nodes.req, "vsplit")
+      assert.are.equal(2, #vim.api.nvim_list_wins())
+      local current = vim.api.nvim_get_current_win()
+      assert.are_not.equal(win, current)
+      assert.are.same({ 7, 2 }, vim.api.nvim_win_get_cursor(current))
+    end)
+
+    it("does not close the view twice when the adapter reports the close", function()
+      view.close = function()
+        table.insert(calls, { "close" })
+        ctrl:closed()
+      end
+      attach()
+      ctrl:confirm(nodes.main)
+      assert.are.equal(1, count_of(calls, "close"))
+      assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+    end)
+  end)
+
+  describe("toggle_levels", function()
+    before_each(function()
+      attach()
+      ctrl:drill(nodes.handle)
+      ctrl:drill(nodes.validate)
+      for i = #calls, 1, -1 do
+        calls[i] = nil
+      end
+    end)
+
+    it("lists the breadcrumb and focuses the current scope", function()
+      ctrl:toggle_levels()
+      assert.are.same({ { nodes.root, nodes.handle, nodes.validate } }, args_of(calls, "show"))
+      assert.are.same({ 3 }, args_of(calls, "focus"))
+      assert.are.same({ "sample.go > HandleRequest > Validate [levels]" }, args_of(calls, "set_title"))
+    end)
+
+    it("returns to the current level when called again", function()
+      ctrl:toggle_levels()
+      ctrl:toggle_levels()
+      assert.are.same({ nodes.err }, args_of(calls, "show")[2])
+      assert.are.equal("sample.go > HandleRequest > Validate", args_of(calls, "set_title")[2])
+    end)
+
+    it("confirm on a level shows it, focusing the scope below it", function()
+      ctrl:toggle_levels()
+      ctrl:confirm(nodes.root)
+      assert.is_false(ctrl:is_closed())
+      assert.are.equal(nodes.root, nav:current())
+      assert.are.same({ nodes.handle, nodes.main }, args_of(calls, "show")[2])
+      assert.are.same({ 3, 1 }, args_of(calls, "focus"))
+      assert.are.same({ 50, 3 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("drill on a level shows it", function()
+      ctrl:toggle_levels()
+      assert.is_true(ctrl:drill(nodes.handle))
+      assert.are.equal(nodes.handle, nav:current())
+      assert.are.same({ 3, 2 }, args_of(calls, "focus"))
+    end)
+
+    it("up returns to the current level", function()
+      ctrl:toggle_levels()
+      assert.is_true(ctrl:up())
+      assert.are.equal(nodes.validate, nav:current())
+      assert.are.same({ nodes.err }, args_of(calls, "show")[2])
+    end)
+
+    it("navigates() is true for every level", function()
+      assert.is_false(ctrl:navigates(nodes.handle))
+      ctrl:toggle_levels()
+      assert.is_true(ctrl:navigates(nodes.handle))
+    end)
+  end)
+
+  describe("toggle_recursive", function()
+    it("lists every descendant of the current scope, depth first", function()
+      attach()
+      ctrl:toggle_recursive()
+      assert.are.same(
+        { { nodes.handle, nodes.req, nodes.validate, nodes.err, nodes.main, nodes.x } },
+        args_of(calls, "show")
+      )
+      assert.are.same({ "sample.go [recursive]" }, args_of(calls, "set_title"))
+    end)
+
+    it("labels each item with its path below the current scope", function()
+      attach()
+      assert.are.equal("err", ctrl:label(nodes.err))
+      ctrl:toggle_recursive()
+      assert.are.equal("HandleRequest > Validate > err", ctrl:label(nodes.err))
+      assert.are.equal("HandleRequest > Validate > err", ctrl:parts(nodes.err)[2].text)
+      ctrl:toggle_recursive()
+      ctrl:drill(nodes.handle)
+      ctrl:toggle_recursive()
+      assert.are.equal("Validate > err", ctrl:label(nodes.err))
+    end)
+
+    it("returns to the current level when called again", function()
+      attach()
+      ctrl:drill(nodes.handle)
+      ctrl:toggle_recursive()
+      assert.are.same({ nodes.req, nodes.validate, nodes.err }, args_of(calls, "show")[2])
+      ctrl:toggle_recursive()
+      assert.are.same({ nodes.req, nodes.validate }, args_of(calls, "show")[3])
+      assert.are.equal("err", ctrl:label(nodes.err))
+    end)
+
+    it("drill on a result shows the level it is at, selecting it", function()
+      attach()
+      ctrl:toggle_recursive()
+      assert.is_true(ctrl:drill(nodes.err))
+      assert.are.equal(nodes.validate, nav:current())
+      assert.are.equal("sample.go > HandleRequest > Validate", ctrl:title())
+      assert.are.same({ nodes.err }, args_of(calls, "show")[2])
+      assert.are.same({ 1 }, args_of(calls, "focus"))
+    end)
+
+    it("confirm on a result jumps to it", function()
+      attach()
+      ctrl:toggle_recursive()
+      ctrl:confirm(nodes.err)
+      assert.is_true(ctrl:is_closed())
+      assert.are.same({ 12, 4 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("up returns to the current level", function()
+      attach()
+      ctrl:toggle_recursive()
+      assert.is_true(ctrl:up())
+      assert.are.equal(nodes.root, nav:current())
+      assert.are.same({ nodes.handle, nodes.main }, args_of(calls, "show")[2])
+    end)
+  end)
+
+  describe("nav entries", function()
+    before_each(function()
+      nav = Navigator.new(scope_tree, { nav_entries = true })
+      ctrl = Controller.new(nav, bufnr)
+      attach()
+      ctrl:drill(nodes.handle)
+    end)
+
+    it("confirm on `..` goes up and keeps the picker open", function()
+      local parent_entry = nav:items()[1]
+      assert.is_true(ctrl:navigates(parent_entry))
+      ctrl:confirm(parent_entry)
+      assert.is_false(ctrl:is_closed())
+      assert.are.equal(nodes.root, nav:current())
+    end)
+
+    it("confirm on `.` jumps to the current scope's start", function()
+      local self_entry = nav:items()[2]
+      assert.is_false(ctrl:navigates(self_entry))
+      ctrl:confirm(self_entry)
+      assert.is_true(ctrl:is_closed())
+      assert.are.same({ 6, 0 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("drill on `..` goes up, focusing the scope just left", function()
+      ctrl:drill(nav:items()[1])
+      assert.are.equal(nodes.root, nav:current())
+      assert.are.same({ 1 }, args_of(calls, "focus"))
+    end)
+
+    it("refresh shows the recomputed items with the new node", function()
+      local extra = ScopeNode.new({
+        name = "extra",
+        kind = "variable",
+        range = { start_row = 25, start_col = 0, end_row = 25, end_col = 1 },
+      })
+      nodes.handle:add_child(extra)
+      for i = #calls, 1, -1 do
+        calls[i] = nil
+      end
+      ctrl:refresh()
+      assert.are.equal(0, count_of(calls, "refresh"))
+      local shown = args_of(calls, "show")[1]
+      assert.are.equal(5, #shown)
+      assert.are.equal(extra, shown[5])
+    end)
+  end)
+
+  describe("closed", function()
+    it("restores the cursor when nothing was confirmed", function()
+      attach()
+      vim.api.nvim_win_set_cursor(win, { 3, 0 })
+      ctrl:closed()
+      assert.are.same({ 50, 3 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("stops further updates to the view", function()
+      attach()
+      ctrl:closed()
+      ctrl:refresh()
+      ctrl:drill(nodes.handle)
+      assert.are.equal(0, count_of(calls, "refresh"))
+      assert.are.same({}, args_of(calls, "show"))
+    end)
+  end)
+
+  describe("preview", function()
+    local PEEK_NS = vim.api.nvim_create_namespace("scopes_peek")
+
+    local function peek_marks()
+      return vim.api.nvim_buf_get_extmarks(bufnr, PEEK_NS, 0, -1, { details = true })
+    end
+
+    local function topline()
+      return vim.api.nvim_win_call(win, vim.fn.winsaveview).topline
+    end
+
+    before_each(function()
+      vim.api.nvim_win_call(win, function()
+        vim.fn.winrestview({ topline = 45, lnum = 50, col = 3 })
+      end)
+      ctrl = Controller.new(nav, bufnr)
+      attach()
+    end)
+
+    it("highlights the node's range and moves the cursor to its start", function()
+      ctrl:preview(nodes.validate)
+      local marks = peek_marks()
+      assert.are.equal(1, #marks)
+      assert.are.equal(10, marks[1][2])
+      assert.are.equal(2, marks[1][3])
+      assert.are.equal(20, marks[1][4].end_row)
+      assert.are.equal(3, marks[1][4].end_col)
+      assert.are.same({ 11, 2 }, vim.api.nvim_win_get_cursor(win))
+    end)
+
+    it("scrolls the whole range into view", function()
+      ctrl:preview(nodes.validate)
+      local first = topline()
+      local height = vim.api.nvim_win_get_height(win)
+      assert.is_true(first <= 11)
+      assert.is_true(first + height - 1 >= 21)
+    end)
+
+    it("puts the start of a range taller than the window at the top", function()
+      local tall = ScopeNode.new({
+        name = "tall",
+        kind = "function",
+        range = { start_row = 20, start_col = 0, end_row = 95, end_col = 1 },
+      })
+      ctrl:preview(tall)
+      assert.are.equal(21, topline())
+    end)
+
+    it("moves the highlight with the selection", function()
+      ctrl:preview(nodes.validate)
+      ctrl:preview(nodes.main)
+      local marks = peek_marks()
+      assert.are.equal(1, #marks)
+      assert.are.equal(40, marks[1][2])
+    end)
+
+    it("restores the cursor and topline on cancel", function()
+      ctrl:preview(nodes.handle)
+      ctrl:closed()
+      assert.are.same({ 50, 3 }, vim.api.nvim_win_get_cursor(win))
+      assert.are.equal(45, topline())
+      assert.are.same({}, peek_marks())
+    end)
+
+    it("keeps the cursor at the symbol on confirm", function()
+      ctrl:preview(nodes.handle)
+      ctrl:confirm(nodes.main)
+      assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+      assert.are.same({}, peek_marks())
+    end)
+
+    it("does nothing with picker.preview = false", function()
+      config.merge({ picker = { preview = false } })
+      ctrl:preview(nodes.handle)
+      assert.are.same({ 50, 3 }, vim.api.nvim_win_get_cursor(win))
+      assert.are.same({}, peek_marks())
+    end)
+  end)
+
+  describe("refresh", function()
+    it("re-reads the items in place", function()
+      attach()
+      ctrl:refresh()
+      assert.are.equal(1, count_of(calls, "refresh"))
+      assert.are.same({ "sample.go" }, args_of(calls, "set_title"))
+    end)
+
+    it("recomputes the recursive listing after children are added", function()
+      attach()
+      ctrl:toggle_recursive()
+      local y = ScopeNode.new({
+        name = "y",
+        kind = "variable",
+        range = { start_row = 42, start_col = 2, end_row = 42, end_col = 3 },
+      })
+      nodes.main:add_child(y)
+      ctrl:refresh()
+      local shown = args_of(calls, "show")
+      assert.are.equal(2, #shown)
+      assert.are.same({ nodes.handle, nodes.req, nodes.validate, nodes.err, nodes.main, nodes.x, y }, shown[2])
+      assert.are.equal(0, count_of(calls, "refresh"))
+      assert.are.same({ "sample.go [recursive]", "sample.go [recursive]" }, args_of(calls, "set_title"))
+    end)
+
+    it("recomputes the listed levels", function()
+      attach()
+      nav:open_at(nodes.validate)
+      ctrl:toggle_levels()
+      ctrl:refresh()
+      local shown = args_of(calls, "show")
+      assert.are.same({ nodes.root, nodes.handle, nodes.validate }, shown[#shown])
+      assert.are.equal(0, count_of(calls, "refresh"))
+    end)
+
+    it("with focus_cursor, shows the current level and focuses the cursor's item", function()
+      attach()
+      nav:open_at_cursor(41, 2)
+      ctrl:refresh({ focus_cursor = true })
+      assert.are.same({ { nodes.x } }, args_of(calls, "show"))
+      assert.are.same({ 1 }, args_of(calls, "focus"))
+    end)
+  end)
+end)
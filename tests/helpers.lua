@@ -135,6 +135,82 @@ function M.find_ts_nodes(root, node_type)
   return results
 end
 
+--- Start an in-process fake language server and attach it to `bufnr`.
+--- `responses` maps LSP method names to canned results, or to functions
+--- `fun(params): any` that compute one. Unlisted methods resolve to nil.
+--- Set opts.delay_ms to simulate a slow server.
+--- Stop it with helpers.stop_fake_lsp(client_id) in after_each.
+--- @param bufnr number
+--- @param responses table<string, any>
+--- @param opts? {delay_ms?: number}
+--- @return integer client_id
+function M.start_fake_lsp(bufnr, responses, opts)
+  opts = opts or {}
+  local request_id = 0
+  local cmd = function(dispatchers)
+    local closing = false
+    return {
+      request = function(method, params, callback)
+        request_id = request_id + 1
+        local result
+        if method == "initialize" then
+          result = { capabilities = { documentSymbolProvider = true } }
+        else
+          result = responses[method]
+          if type(result) == "function" then
+            result = result(params)
+          end
+        end
+        local respond = function()
+          callback(nil, result)
+        end
+        if opts.delay_ms and method ~= "initialize" and method ~= "shutdown" then
+          vim.defer_fn(respond, opts.delay_ms)
+        else
+          vim.schedule(respond)
+        end
+        return true, request_id
+      end,
+      notify = function(method, _params)
+        if method == "exit" then
+          dispatchers.on_exit(0, 15)
+        end
+        return true
+      end,
+      is_closing = function()
+        return closing
+      end,
+      terminate = function()
+        closing = true
+      end,
+    }
+  end
+
+  M._fake_lsp_count = (M._fake_lsp_count or 0) + 1
+  local client_id = vim.lsp.start({
+    -- Unique name so vim.lsp.start never reuses a client from an earlier test.
+    name = "scopes-fake-lsp-" .. M._fake_lsp_count,
+    cmd = cmd,
+    root_dir = vim.uv.cwd(),
+  }, { bufnr = bufnr })
+  vim.wait(1000, function()
+    local client = vim.lsp.get_client_by_id(client_id)
+    return client ~= nil and client.initialized == true
+  end)
+  return client_id
+end
+
+--- Stop a fake language server started by start_fake_lsp.
+--- @param client_id integer|nil
+function M.stop_fake_lsp(client_id)
+  if client_id then
+    vim.lsp.stop_client(client_id, true)
+    vim.wait(1000, function()
+      return vim.lsp.get_client_by_id(client_id) == nil
+    end)
+  end
+end
+
 --- Valid kind strings for all built-in language configs.
 M.valid_kinds = {
   ["function"] = true,
@@ -8,7 +8,7 @@ describe("icons", function()
 
   describe("get_icon (built-in fallback)", function()
     it("returns a non-empty string for all known kinds", function()
-      local kinds = { "function", "method", "class", "struct", "variable", "const", "type", "block", "module" }
+      local kinds = { "function", "method", "class", "struct", "interface", "variable", "const", "type", "block", "module" }
       for _, kind in ipairs(kinds) do
         local icon = icons.get_icon(kind)
         assert.is_string(icon)
@@ -128,4 +128,83 @@ describe("lang_config", function()
       assert.is_nil(cfg)
     end)
   end)
+
+  describe("load() with query files", function()
+    local tmp_dir
+
+    before_each(function()
+      tmp_dir = vim.fn.tempname()
+      vim.fn.mkdir(tmp_dir .. "/queries/go", "p")
+      vim.fn.writefile({
+        "(function_declaration name: (identifier) @scope.name) @scope.function",
+      }, tmp_dir .. "/queries/go/scopes.scm")
+      vim.opt.runtimepath:prepend(tmp_dir)
+    end)
+
+    after_each(function()
+      vim.opt.runtimepath:remove(tmp_dir)
+      vim.fn.delete(tmp_dir, "rf")
+    end)
+
+    it("prefers queries/<lang>/scopes.scm over the Lua language file", function()
+      local cfg = lang_config.load("go")
+      assert.is_truthy(cfg)
+      assert.is_truthy(cfg.query)
+      assert.are.equal("function", type(cfg.prepare))
+    end)
+
+    it("parses the query files once", function()
+      local parse = vim.treesitter.query.parse
+      local calls = 0
+      vim.treesitter.query.parse = function(...)
+        calls = calls + 1
+        return parse(...)
+      end
+      local first = lang_config.load("go")
+      local second = lang_config.load("go")
+      vim.treesitter.query.parse = parse
+      assert.are.equal(1, calls)
+      assert.are.equal(first.query, second.query)
+      assert.are_not.equal(first, second)
+    end)
+
+    it("falls back to the Lua language file when no query file exists", function()
+      vim.opt.runtimepath:remove(tmp_dir)
+      local cfg = lang_config.load("go")
+      assert.is_truthy(cfg)
+      assert.is_nil(cfg.query)
+    end)
+
+    it("ships a query for markdown", function()
+      local cfg = lang_config.load("markdown")
+      assert.is_truthy(cfg)
+      assert.is_truthy(cfg.query)
+    end)
+  end)
+
+  describe("generic()", function()
+    it("returns nil when no parser is installed for the language", function()
+      assert.is_nil(lang_config.generic("no_such_language_xyz"))
+    end)
+
+    it("builds a config from an installed grammar (c)", function()
+      local cfg = lang_config.generic("c")
+      assert.is_truthy(cfg)
+      assert.is_true(cfg.is_generic)
+      assert.are.equal("function", type(cfg.classify))
+      assert.are.equal("function", type(cfg.get_name))
+    end)
+
+    it("guesses kinds from node type names", function()
+      local cfg = lang_config.generic("c")
+      assert.are.equal("function", cfg.kind_map["function_definition"])
+      assert.are.equal("struct", cfg.kind_map["struct_specifier"])
+      assert.are.equal("variable", cfg.kind_map["field_declaration"])
+    end)
+
+    it("never treats parameters as candidates", function()
+      local cfg = lang_config.generic("c")
+      assert.is_nil(cfg.node_types["parameter_declaration"])
+    end)
+  end)
 end)
@@ -169,6 +169,46 @@ describe("Navigator", function()
     end)
   end)
 
+  describe("lazy nodes", function()
+    --- Root with one deferred scope whose child is built on expand().
+    local function make_lazy_tree()
+      local root = ScopeNode.new({
+        name = "sample.go",
+        kind = "module",
+        range = { start_row = 0, start_col = 0, end_row = 99, end_col = 0 },
+      })
+      local fn = ScopeNode.new({
+        name = "lazy",
+        kind = "function",
+        range = { start_row = 5, start_col = 0, end_row = 30, end_col = 1 },
+      })
+      fn:defer(function()
+        fn:add_child(ScopeNode.new({
+          name = "inner",
+          kind = "function",
+          range = { start_row = 10, start_col = 2, end_row = 20, end_col = 3 },
+        }))
+      end, true)
+      root:add_child(fn)
+      local scope_tree = ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+      return scope_tree, fn
+    end
+
+    it("drill_down() builds the children of a deferred node", function()
+      local scope_tree, fn = make_lazy_tree()
+      local nav = Navigator.new(scope_tree)
+      assert.is_true(nav:drill_down(fn))
+      assert.are.equal(1, #nav:items())
+      assert.are.equal("inner", nav:items()[1].name)
+    end)
+
+    it("cursor_row resolves through deferred nodes", function()
+      local scope_tree = make_lazy_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 15 })
+      assert.are.equal("inner", nav:current().name)
+    end)
+  end)
+
   describe("go_up", function()
     it("moves current back to parent", function()
       local scope_tree, nodes = make_test_tree()
@@ -275,6 +315,105 @@ describe("Navigator", function()
     end)
   end)
 
+  describe("breadcrumb", function()
+    it("returns the path from the root to the current node", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 15 })
+      assert.are.same({ nodes.root, nodes.handle, nodes.validate }, nav:breadcrumb())
+    end)
+
+    it("returns a copy", function()
+      local scope_tree = make_test_tree()
+      local nav = Navigator.new(scope_tree)
+      table.insert(nav:breadcrumb(), "x")
+      assert.are.equal(1, #nav:breadcrumb())
+    end)
+  end)
+
+  describe("nav_entries", function()
+    it("lists `..` and `.` before a scope's children", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { nav_entries = true })
+      nav:drill_down(nodes.handle)
+      local items = nav:items()
+      assert.are.equal(4, #items)
+      assert.are.equal("..", items[1].name)
+      assert.are.equal("parent", items[1].synthetic)
+      assert.are.equal(nodes.root, items[1].target)
+      assert.are.equal(".", items[2].name)
+      assert.are.equal("self", items[2].synthetic)
+      assert.are.equal(nodes.handle, items[2].target)
+      assert.are.equal(nodes.req, items[3])
+      assert.are.equal(nodes.validate, items[4])
+    end)
+
+    it("are not listed at the root", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { nav_entries = true })
+      assert.are.same({ nodes.handle, nodes.main_fn }, nav:items())
+    end)
+
+    it("are off by default", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree)
+      nav:drill_down(nodes.handle)
+      assert.are.same({ nodes.req, nodes.validate }, nav:items())
+    end)
+
+    it("keep their identity across calls", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { nav_entries = true })
+      nav:drill_down(nodes.handle)
+      assert.are.equal(nav:items()[1], nav:items()[1])
+    end)
+
+    it("drilling into `..` goes up", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { nav_entries = true })
+      nav:drill_down(nodes.handle)
+      nav:drill_down(nodes.validate)
+      assert.is_true(nav:drill_down(nav:items()[1]))
+      assert.are.equal(nodes.handle, nav:current())
+      assert.are.equal("sample.go > HandleRequest", nav:breadcrumb_string())
+    end)
+
+    it("`.` is a leaf that enters at the current scope's start", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { nav_entries = true })
+      nav:drill_down(nodes.handle)
+      nav:drill_down(nodes.validate)
+      local self_entry = nav:items()[2]
+      assert.is_false(nav:drill_down(self_entry))
+      assert.are.equal(nodes.validate, nav:current())
+      assert.are.same({ row = 10, col = 2 }, nav:enter(self_entry))
+    end)
+
+    it("cursor_index() skips the entries", function()
+      local scope_tree = make_test_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 11, nav_entries = true })
+      -- In Validate: `..`, `.`, err
+      assert.are.equal(3, nav:cursor_index())
+    end)
+  end)
+
+  describe("open_at", function()
+    it("makes the node current with its ancestors as the breadcrumb", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree)
+      nav:open_at(nodes.validate)
+      assert.are.equal(nodes.validate, nav:current())
+      assert.are.equal("sample.go > HandleRequest > Validate", nav:breadcrumb_string())
+    end)
+
+    it("goes up through the node's real parents", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree)
+      nav:open_at(nodes.validate)
+      assert.is_true(nav:go_up())
+      assert.are.equal(nodes.handle, nav:current())
+    end)
+  end)
+
   describe("open_at_cursor", function()
     it("navigates to deepest scope containing the row", function()
       local scope_tree, nodes = make_test_tree()
@@ -331,6 +470,104 @@ describe("Navigator", function()
     end)
   end)
 
+  describe("column-precise resolution", function()
+    --- One line holding two func literals, each with a statement inside:
+    ---   row 3: run(func() { a() }, func() { b() })
+    ---              ^col 4-19          ^col 21-36
+    local function make_one_line_tree()
+      local root = ScopeNode.new({
+        name = "sample.go",
+        kind = "module",
+        range = { start_row = 0, start_col = 0, end_row = 9, end_col = 0 },
+      })
+      local first = ScopeNode.new({
+        name = "func_1",
+        kind = "function",
+        range = { start_row = 3, start_col = 4, end_row = 3, end_col = 19 },
+      })
+      local second = ScopeNode.new({
+        name = "func_2",
+        kind = "function",
+        range = { start_row = 3, start_col = 21, end_row = 3, end_col = 36 },
+      })
+      first:add_child(ScopeNode.new({
+        name = "a",
+        kind = "variable",
+        range = { start_row = 3, start_col = 13, end_row = 3, end_col = 16 },
+      }))
+      second:add_child(ScopeNode.new({
+        name = "b",
+        kind = "variable",
+        range = { start_row = 3, start_col = 30, end_row = 3, end_col = 33 },
+      }))
+      root:add_child(first)
+      root:add_child(second)
+      local scope_tree = ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+      return scope_tree, { root = root, first = first, second = second }
+    end
+
+    it("picks the scope under the cursor column", function()
+      local scope_tree, nodes = make_one_line_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 3, cursor_col = 25 })
+      assert.are.equal(nodes.second, nav:current())
+      nav:open_at_cursor(3, 6)
+      assert.are.equal(nodes.first, nav:current())
+    end)
+
+    it("falls back to root when the column is outside both scopes", function()
+      local scope_tree, nodes = make_one_line_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 3, cursor_col = 1 })
+      assert.are.equal(nodes.root, nav:current())
+    end)
+
+    it("compares rows only when no column is given", function()
+      local scope_tree, nodes = make_one_line_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 3 })
+      assert.are.equal(nodes.first, nav:current())
+    end)
+
+    it("resolves two scopes on one row to the first without a column", function()
+      local scope_tree, nodes = make_one_line_tree()
+      local nav = Navigator.new(scope_tree)
+      nav:open_at_cursor(3)
+      assert.are.equal("sample.go > func_1", nav:breadcrumb_string())
+      nav:go_up()
+      assert.are.equal(nodes.first, nav:items()[nav:cursor_index()])
+    end)
+  end)
+
+  describe("cursor_index", function()
+    it("returns the index of the item containing the cursor", function()
+      local scope_tree = make_test_tree()
+      -- (11, 5) is inside err, Validate's only item
+      local nav = Navigator.new(scope_tree, { cursor_row = 11, cursor_col = 5 })
+      assert.are.equal(1, nav:cursor_index())
+      nav:go_up()
+      -- Validate is HandleRequest's second item
+      assert.are.equal(2, nav:cursor_index())
+    end)
+
+    it("returns nil when no item contains the cursor", function()
+      local scope_tree = make_test_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 35, cursor_col = 0 })
+      assert.is_nil(nav:cursor_index())
+    end)
+
+    it("returns nil after navigating away from the cursor", function()
+      local scope_tree, nodes = make_test_tree()
+      local nav = Navigator.new(scope_tree, { cursor_row = 15, cursor_col = 2 })
+      nav:go_up()
+      nav:go_up()
+      nav:drill_down(nodes.main_fn)
+      assert.is_nil(nav:cursor_index())
+    end)
+
+    it("returns nil when no cursor was given", function()
+      local scope_tree = make_test_tree()
+      assert.is_nil(Navigator.new(scope_tree):cursor_index())
+    end)
+  end)
+
   describe("sequence tests", function()
     it("drill → drill → up → up returns to original state", function()
       local scope_tree, nodes = make_test_tree()
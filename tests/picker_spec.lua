@@ -1,6 +1,7 @@
 --- Tests for lua/scopes/picker.lua
---- Only tests pure functions (make_item, format). open() is not tested here
---- because it depends on snacks.nvim which is not available in the test env.
+--- Tests the snacks item helpers (make_item, format) and adapter selection in
+--- open(). telescope.nvim, fzf-lua and mini.pick are not available in the test
+--- env, so selecting them exercises the fallback to the built-in picker.
 
 local tree_mod = require("scopes.tree")
 local ScopeNode = tree_mod.ScopeNode
@@ -201,4 +202,112 @@ describe("picker", function()
       assert.truthy(first[1] and #first[1] > 0, "icon should be non-empty")
     end)
   end)
+
+  describe("open", function()
+    local config = require("scopes.config")
+    local helpers = require("tests.helpers")
+
+    local handle
+
+    after_each(function()
+      if handle and not handle:is_closed() then
+        handle.view:close()
+      end
+      handle = nil
+      config.merge({})
+    end)
+
+    local function make_nav()
+      local root = make_scope_node({
+        name = "file.go",
+        kind = "file",
+        range = { start_row = 0, start_col = 0, end_row = 10, end_col = 0 },
+      })
+      return require("scopes.navigator").new(
+        tree_mod.ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+      )
+    end
+
+    --- Returns true if the current window is the native picker's prompt.
+    local function in_native_prompt()
+      return handle ~= nil and vim.api.nvim_get_current_win() == handle.view.prompt_win
+    end
+
+    -- telescope.nvim, fzf-lua and mini.pick are not installed in the test environment.
+    for _, backend in ipairs({ "telescope", "fzf-lua", "mini.pick" }) do
+      it("falls back to the built-in picker when " .. backend .. " is missing", function()
+        config.merge({ picker = { backend = backend } })
+        local warnings, restore = helpers.capture_notify()
+        handle = picker.open(make_nav(), vim.api.nvim_get_current_buf())
+        restore()
+        assert.is_not_nil(handle)
+        assert.is_true(in_native_prompt())
+        assert.are.same({}, warnings)
+      end)
+    end
+
+    it("warns about an unknown backend and uses the built-in picker", function()
+      config.merge({ picker = { backend = "nope" } })
+      local warnings, restore = helpers.capture_notify()
+      handle = picker.open(make_nav(), vim.api.nvim_get_current_buf())
+      restore()
+      assert.is_true(in_native_prompt())
+      assert.is_truthy(warnings[1]:find("unknown picker.backend 'nope'", 1, true))
+    end)
+
+    it("uses an adapter added with register()", function()
+      local opened_with = nil
+      local view = {
+        show = function() end,
+        set_title = function() end,
+        refresh = function() end,
+        focus = function() end,
+        close = function() end,
+      }
+      picker.register("test-adapter", {
+        open = function(ctrl)
+          opened_with = ctrl
+          return view
+        end,
+      })
+      config.merge({ picker = { backend = "test-adapter" } })
+      local ctrl = picker.open(make_nav(), vim.api.nvim_get_current_buf())
+      assert.are.equal(ctrl, opened_with)
+      assert.are.equal(view, ctrl.view)
+      ctrl:closed()
+    end)
+
+    it("falls back when a registered adapter is not available", function()
+      picker.register("unavailable", {
+        available = function()
+          return false
+        end,
+        open = function()
+          error("must not be opened")
+        end,
+      })
+      config.merge({ picker = { backend = "unavailable" } })
+      handle = picker.open(make_nav(), vim.api.nvim_get_current_buf())
+      assert.is_true(in_native_prompt())
+    end)
+
+    it("returns nil when the adapter cannot open", function()
+      picker.register("broken", {
+        open = function()
+          return nil
+        end,
+      })
+      config.merge({ picker = { backend = "broken" } })
+      assert.is_nil(picker.open(make_nav(), vim.api.nvim_get_current_buf()))
+    end)
+  end)
+
+  describe("register", function()
+    it("warns and ignores an adapter without open()", function()
+      local warnings, restore = require("tests.helpers").capture_notify()
+      picker.register("bad", {})
+      restore()
+      assert.is_truthy(warnings[1]:find("picker.register()", 1, true))
+    end)
+  end)
 end)
@@ -0,0 +1,329 @@
+--- Tests for lua/scopes/pickers/fzf_lua.lua
+--- fzf-lua is not installed in the test env, so its modules are stubbed
+--- through package.loaded. The fzf_exec stub records the contents and options;
+--- tests read the list, run the actions and evaluate the load bind themselves.
+--- Keys sent to fzf's terminal are recorded instead of sent.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local Navigator = require("scopes.navigator")
+local Controller = require("scopes.controller")
+local fzf_lua = require("scopes.pickers.fzf_lua")
+
+local MODULES = { "fzf-lua", "fzf-lua.utils", "fzf-lua.previewer.builtin" }
+
+--- Build a tree: root > { HandleRequest > { req, Validate }, main > { x } }.
+--- @param bufnr number
+--- @return ScopeTree, table
+local function make_test_tree(bufnr)
+  local function node(name, kind, start_row, end_row)
+    return ScopeNode.new({
+      name = name,
+      kind = kind,
+      range = { start_row = start_row, start_col = 0, end_row = end_row, end_col = 1 },
+    })
+  end
+  local root = node("sample.go", "module", 0, 99)
+  local handle = node("HandleRequest", "function", 5, 30)
+  local req = node("req", "variable", 6, 6)
+  local validate = node("Validate", "function", 10, 20)
+  local main_fn = node("main", "function", 40, 60)
+  local x = node("x", "variable", 41, 41)
+  root:add_child(handle)
+  root:add_child(main_fn)
+  handle:add_child(req)
+  handle:add_child(validate)
+  main_fn:add_child(x)
+  local scope_tree = tree_mod.ScopeTree.new({ root = root, source = "treesitter", bufnr = bufnr, lang = "go" })
+  return scope_tree, { root = root, handle = handle, req = req, validate = validate, main = main_fn, x = x }
+end
+
+--- Install fzf-lua stubs into package.loaded. fzf_exec opens a terminal float
+--- standing in for fzf's window and runs winopts.on_create in it.
+--- @return table state  { contents, opts, term_win, runs }
+local function stub_fzf_lua()
+  local state = { runs = 0 }
+
+  local Base = {}
+  Base.__index = Base
+  function Base:extend()
+    local cls = setmetatable({}, { __index = self })
+    cls.__index = cls
+    cls.super = self
+    return cls
+  end
+  function Base:new(_, _, fzf_win)
+    self.win = fzf_win
+    return self
+  end
+  function Base:get_tmp_buffer()
+    return vim.api.nvim_create_buf(false, true)
+  end
+  function Base:set_preview_buf(buf)
+    self.preview_bufnr = buf
+  end
+
+  package.loaded["fzf-lua"] = {
+    fzf_exec = function(contents, opts)
+      state.runs = state.runs + 1
+      state.contents = contents
+      state.opts = opts
+      local term = vim.api.nvim_create_buf(false, true)
+      vim.api.nvim_open_term(term, {})
+      state.term_win =
+        vim.api.nvim_open_win(term, true, { relative = "editor", row = 0, col = 0, width = 40, height = 10 })
+      opts.winopts.on_create()
+    end,
+  }
+  package.loaded["fzf-lua.utils"] = {
+    ansi_codes = setmetatable({}, {
+      __index = function()
+        return function(text)
+          return text
+        end
+      end,
+    }),
+  }
+  package.loaded["fzf-lua.previewer.builtin"] = { base = Base }
+  return state
+end
+
+describe("fzf-lua picker", function()
+  local bufnr, win, nodes, ctrl, view, state, sent, chan_send
+
+  --- Open the picker and wait for the scheduled fzf_exec().
+  --- @param nav_opts? table  passed to Navigator.new
+  local function open(nav_opts)
+    local scope_tree
+    scope_tree, nodes = make_test_tree(bufnr)
+    ctrl = Controller.new(Navigator.new(scope_tree, nav_opts), bufnr)
+    assert.is_true(ctrl:attach(fzf_lua))
+    view = ctrl.view
+    vim.wait(200, function()
+      return state.opts ~= nil
+    end)
+    assert.is_not_nil(state.opts)
+  end
+
+  --- Read the list fzf would show, header first.
+  --- @return string[]
+  local function load()
+    local lines = {}
+    state.contents(function(line)
+      if line then
+        table.insert(lines, line)
+      end
+    end)
+    return lines
+  end
+
+  --- Run the action bound to `key` on the list line of `node`.
+  local function press(key, node)
+    local line = nil
+    for _, l in ipairs(load()) do
+      if node and l:find(node.name, 1, true) and l:match("^%d+\t") then
+        line = l
+      end
+    end
+    state.opts.actions[key].fn({ line })
+  end
+
+  --- Run the load bind's transform command, as fzf does after a (re)load.
+  --- @return string  the fzf action it prints
+  local function load_action()
+    local cmd = state.opts.fzf_opts["--bind"]:match("^load:transform%((.-)%),")
+    return vim.trim(vim.fn.system(cmd))
+  end
+
+  --- Wait for scheduled sends and return the bytes sent to fzf's terminal.
+  --- @return string[]
+  local function sent_keys()
+    vim.wait(50, function()
+      return false
+    end)
+    local term_chan = vim.bo[vim.api.nvim_win_get_buf(state.term_win)].channel
+    return vim.tbl_map(function(s)
+      assert.are.equal(term_chan, s[1])
+      return s[2]
+    end, sent)
+  end
+
+  before_each(function()
+    bufnr = vim.api.nvim_create_buf(false, true)
+    local lines = {}
+    for i = 1, 100 do
+      lines[i] = "line " .. i
+    end
+    vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 1, 0 })
+    state = stub_fzf_lua()
+    sent = {}
+    chan_send = vim.api.nvim_chan_send
+    vim.api.nvim_chan_send = function(chan, data)
+      table.insert(sent, { chan, data })
+    end
+  end)
+
+  after_each(function()
+    vim.api.nvim_chan_send = chan_send
+    if state.opts then
+      state.opts.winopts.on_close()
+    end
+    if state.term_win and vim.api.nvim_win_is_valid(state.term_win) then
+      vim.api.nvim_win_close(state.term_win, true)
+    end
+    for _, name in ipairs(MODULES) do
+      package.loaded[name] = nil
+    end
+    vim.api.nvim_set_current_win(win)
+    vim.cmd("only")
+    vim.api.nvim_buf_delete(bufnr, { force = true })
+  end)
+
+  it("lists the current level under the breadcrumb header", function()
+    open()
+    local lines = load()
+    assert.are.equal(1, state.opts.fzf_opts["--header-lines"])
+    assert.are.equal("sample.go", lines[1])
+    assert.are.equal(3, #lines)
+    assert.is_truthy(lines[2]:find("^1\t.*HandleRequest %[function%] :6"))
+    assert.is_truthy(lines[3]:find("^2\t.*main %[function%] :41"))
+  end)
+
+  it("starts on the item under the cursor", function()
+    open({ cursor_row = 10 })
+    assert.are.equal("pos(2)", load_action())
+    -- Consumed by that load: a later plain reload keeps fzf's position.
+    assert.are.equal("", load_action())
+    assert.are.same({}, sent_keys())
+  end)
+
+  it("drills down on the drill key and retitles the header", function()
+    open()
+    press("tab", nodes.handle)
+    assert.are.equal(nodes.handle, ctrl.nav:current())
+    local lines = load()
+    assert.are.equal("sample.go > HandleRequest", lines[1])
+    assert.is_truthy(lines[2]:find("req", 1, true))
+    assert.are.equal("first", load_action())
+    -- The action's own reload picks the change up.
+    assert.are.same({}, sent_keys())
+  end)
+
+  it("goes up on the go-up key and selects the scope just left", function()
+    open()
+    press("tab", nodes.main)
+    press("shift-tab")
+    assert.are.equal(nodes.root, ctrl.nav:current())
+    assert.are.equal("sample.go", load()[1])
+    assert.are.equal("pos(2)", load_action())
+  end)
+
+  it("jumps to the selection in the current window", function()
+    open()
+    press("default", nodes.main)
+    assert.is_true(ctrl:is_closed())
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+    -- fzf has already exited: nothing is sent to abort it.
+    assert.are.same({}, sent_keys())
+  end)
+
+  it("opens a vertical split on the split_vertical key", function()
+    open()
+    press("ctrl-v", nodes.main)
+    assert.are.equal("row", vim.fn.winlayout()[1])
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("opens a horizontal split on the split_horizontal key", function()
+    open()
+    press("ctrl-s", nodes.handle)
+    assert.are.equal("col", vim.fn.winlayout()[1])
+    assert.are.same({ 6, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("starts fzf again on the level confirmed from the levels list", function()
+    open()
+    press("tab", nodes.handle)
+    press("ctrl-b")
+    assert.are.equal("sample.go > HandleRequest [levels]", load()[1])
+    -- fzf closes its window before running the closing action.
+    state.opts.winopts.on_close()
+    press("default", nodes.root)
+    vim.wait(200, function()
+      return state.runs == 2
+    end)
+    assert.are.equal(2, state.runs)
+    assert.is_false(ctrl:is_closed())
+    assert.are.equal(nodes.root, ctrl.nav:current())
+    assert.are.equal("sample.go", load()[1])
+    assert.are.equal("pos(1)", load_action())
+  end)
+
+  it("lists every descendant with its path on the recursive key", function()
+    open()
+    press("ctrl-r")
+    local lines = load()
+    assert.are.equal("sample.go [recursive]", lines[1])
+    assert.are.equal(6, #lines)
+    assert.is_truthy(lines[4]:find("HandleRequest > Validate", 1, true))
+    assert.are.equal("first", load_action())
+  end)
+
+  it("previews the node's whole range", function()
+    open()
+    local previewer = state.opts.previewer.new({}, {}, {}, { update_preview_scrollbar = function() end })
+    previewer:populate_preview_buf(load()[2])
+    local lines = vim.api.nvim_buf_get_lines(previewer.preview_bufnr, 0, -1, false)
+    assert.are.equal(26, #lines)
+    assert.are.equal("line 6", lines[1])
+    assert.are.equal("line 31", lines[26])
+  end)
+
+  it("peeks at the previewed node in the main window", function()
+    open()
+    local previewer = state.opts.previewer.new({}, {}, {}, { update_preview_scrollbar = function() end })
+    previewer:populate_preview_buf(load()[3])
+    vim.wait(200, function()
+      return vim.api.nvim_win_get_cursor(win)[1] == 41
+    end)
+    assert.are.equal(41, vim.api.nvim_win_get_cursor(win)[1])
+  end)
+
+  it("reloads a running fzf on refresh() and applies the focus", function()
+    open({ cursor_row = 10 })
+    load_action()
+    ctrl:refresh({ focus_cursor = true })
+    assert.is_not_nil(state.opts.actions["ctrl-alt-r"])
+    assert.are.same({ "\27\18" }, sent_keys())
+    assert.are.equal("pos(2)", load_action())
+  end)
+
+  it("keeps fzf's position on a plain refresh()", function()
+    open()
+    ctrl:refresh()
+    assert.are.same({ "\27\18" }, sent_keys())
+    assert.are.equal("", load_action())
+  end)
+
+  it("close() aborts fzf through its terminal", function()
+    open()
+    assert.is_truthy(state.opts.fzf_opts["--bind"]:find(",ctrl-alt-x:abort$"))
+    view:close()
+    assert.are.same({ "\27\24" }, sent_keys())
+  end)
+
+  it("restores the cursor when fzf closes without a jump", function()
+    vim.api.nvim_win_set_cursor(win, { 12, 0 })
+    open()
+    vim.api.nvim_win_set_cursor(win, { 50, 0 })
+    state.opts.winopts.on_close()
+    vim.wait(200, function()
+      return ctrl:is_closed()
+    end)
+    assert.is_true(ctrl:is_closed())
+    assert.are.same({ 12, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+end)
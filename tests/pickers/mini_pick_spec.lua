@@ -0,0 +1,256 @@
+--- Tests for lua/scopes/pickers/mini_pick.lua
+--- mini.pick is not installed in the test env, so MiniPick is stubbed through
+--- package.loaded. The stub keeps the items, prompt and current match that
+--- the adapter sets. MiniPick.start() blocks while the picker is open, so
+--- tests pass a script that the stub runs from inside start().
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local Navigator = require("scopes.navigator")
+local Controller = require("scopes.controller")
+local mini_pick = require("scopes.pickers.mini_pick")
+
+--- Build a tree: root > { HandleRequest > { req, Validate }, main > { x } }.
+--- @param bufnr number
+--- @return ScopeTree, table
+local function make_test_tree(bufnr)
+  local function node(name, kind, start_row, end_row)
+    return ScopeNode.new({
+      name = name,
+      kind = kind,
+      range = { start_row = start_row, start_col = 0, end_row = end_row, end_col = 1 },
+    })
+  end
+  local root = node("sample.go", "module", 0, 99)
+  local handle = node("HandleRequest", "function", 5, 30)
+  local req = node("req", "variable", 6, 6)
+  local validate = node("Validate", "function", 10, 20)
+  local main_fn = node("main", "function", 40, 60)
+  local x = node("x", "variable", 41, 41)
+  root:add_child(handle)
+  root:add_child(main_fn)
+  handle:add_child(req)
+  handle:add_child(validate)
+  main_fn:add_child(x)
+  local scope_tree = tree_mod.ScopeTree.new({ root = root, source = "treesitter", bufnr = bufnr, lang = "go" })
+  return scope_tree, { root = root, handle = handle, req = req, validate = validate, main = main_fn, x = x }
+end
+
+--- Install a MiniPick stub into package.loaded. start() fires MiniPickStart
+--- like the real picker, then runs `state.script(opts)` as the open picker's
+--- user and returns, as if the picker had closed.
+--- @return table state  { opts, items, prompt_prefix, current, active, script, err }
+local function stub_mini_pick()
+  local state = { active = false }
+  package.loaded["mini.pick"] = {
+    start = function(opts)
+      state.opts = opts
+      state.items = opts.source.items
+      state.prompt_prefix = opts.window.prompt_prefix
+      state.active = true
+      vim.api.nvim_exec_autocmds("User", { pattern = "MiniPickStart" })
+      if state.script then
+        local ok, err = pcall(state.script, opts)
+        state.err = not ok and err or nil
+      end
+      state.active = false
+    end,
+    stop = function()
+      state.stopped = true
+    end,
+    is_picker_active = function()
+      return state.active
+    end,
+    get_picker_items = function()
+      return state.items
+    end,
+    set_picker_items = function(items)
+      state.items = items
+    end,
+    get_picker_matches = function()
+      return { current = state.current }
+    end,
+    set_picker_match_inds = function(inds)
+      state.current = state.items[inds[1]]
+    end,
+    set_picker_opts = function(opts)
+      state.prompt_prefix = opts.window.prompt_prefix
+    end,
+    set_picker_query = function(query)
+      state.query = query
+    end,
+    default_preview = function() end,
+    default_show = function() end,
+  }
+  return state
+end
+
+describe("mini.pick picker", function()
+  local bufnr, win, nodes, ctrl, state
+
+  --- Wait for scheduled work to run.
+  local function settle()
+    vim.wait(50, function()
+      return false
+    end)
+  end
+
+  --- Open the picker and run `script` while it is open. Errors raised by the
+  --- script are re-raised here.
+  --- @param script? fun(opts: table)
+  --- @param nav_opts? table  passed to Navigator.new
+  local function run(script, nav_opts)
+    local scope_tree
+    scope_tree, nodes = make_test_tree(bufnr)
+    ctrl = Controller.new(Navigator.new(scope_tree, nav_opts), bufnr)
+    state.script = script
+    assert.is_true(ctrl:attach(mini_pick))
+    vim.wait(200, function()
+      return state.opts ~= nil
+    end)
+    assert.is_not_nil(state.opts)
+    if state.err then
+      error(state.err, 0)
+    end
+  end
+
+  --- Make the item for `node` the current match.
+  local function select(node)
+    for _, item in ipairs(state.items) do
+      if item.node == node then
+        state.current = item
+      end
+    end
+  end
+
+  local function item_nodes()
+    return vim.tbl_map(function(item)
+      return item.node
+    end, state.items)
+  end
+
+  before_each(function()
+    bufnr = vim.api.nvim_create_buf(false, true)
+    local lines = {}
+    for i = 1, 100 do
+      lines[i] = "line " .. i
+    end
+    vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 1, 0 })
+    state = stub_mini_pick()
+  end)
+
+  after_each(function()
+    package.loaded["mini.pick"] = nil
+    vim.cmd("only")
+    vim.api.nvim_buf_delete(bufnr, { force = true })
+  end)
+
+  it("opens the current level with the breadcrumb in the prompt", function()
+    run()
+    assert.are.equal("sample.go > ", state.prompt_prefix)
+    assert.are.same({ nodes.handle, nodes.main }, item_nodes())
+  end)
+
+  it("takes over mini.pick's own keys that the scope mappings use", function()
+    run()
+    assert.are.equal("<Tab>", state.opts.mappings.scope_drill.char)
+    assert.are.equal("", state.opts.mappings.toggle_preview)
+    assert.are.equal("", state.opts.mappings.toggle_info)
+    assert.are.equal("", state.opts.mappings.choose_in_vsplit)
+  end)
+
+  it("starts on the item under the cursor", function()
+    run(function()
+      settle()
+      assert.are.equal(nodes.validate, state.current.node)
+    end, { cursor_row = 10 })
+  end)
+
+  it("drills down on the drill key and updates the prompt", function()
+    run(function(opts)
+      select(nodes.handle)
+      opts.mappings.scope_drill.func()
+      assert.are.equal(nodes.handle, ctrl.nav:current())
+      assert.are.same({ nodes.req, nodes.validate }, item_nodes())
+      assert.are.equal("sample.go > HandleRequest > ", state.prompt_prefix)
+      assert.are.same({}, state.query)
+    end)
+  end)
+
+  it("goes up on the go-up key and selects the scope just left", function()
+    run(function(opts)
+      select(nodes.main)
+      opts.mappings.scope_drill.func()
+      state.current = nil
+      opts.mappings.scope_up.func()
+      assert.are.equal(nodes.root, ctrl.nav:current())
+      assert.are.equal("sample.go > ", state.prompt_prefix)
+      settle()
+      assert.are.equal(nodes.main, state.current.node)
+    end)
+  end)
+
+  it("jumps to the chosen item in the current window", function()
+    run(function(opts)
+      select(nodes.main)
+      opts.source.choose(state.current)
+    end)
+    settle()
+    assert.is_true(ctrl:is_closed())
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+
+  it("opens a vertical split on the split_vertical key", function()
+    run(function(opts)
+      select(nodes.main)
+      assert.is_true(opts.mappings.scope_split_v.func())
+    end)
+    settle()
+    assert.are.equal("row", vim.fn.winlayout()[1])
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("opens a horizontal split on the split_horizontal key", function()
+    run(function(opts)
+      select(nodes.handle)
+      assert.is_true(opts.mappings.scope_split_h.func())
+    end)
+    settle()
+    assert.are.equal("col", vim.fn.winlayout()[1])
+    assert.are.same({ 6, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("peeks at the current item in the main window", function()
+    run(function(opts)
+      select(nodes.main)
+      opts.source.show(0, state.items, {})
+      settle()
+      assert.are.equal(41, vim.api.nvim_win_get_cursor(win)[1])
+    end)
+  end)
+
+  it("shows the refreshed items while open", function()
+    run(function()
+      local extra = ScopeNode.new({
+        name = "extra",
+        kind = "variable",
+        range = { start_row = 70, start_col = 0, end_row = 70, end_col = 1 },
+      })
+      nodes.root:add_child(extra)
+      ctrl:refresh()
+      assert.are.same({ nodes.handle, nodes.main, extra }, item_nodes())
+    end)
+  end)
+
+  it("restores the cursor when the picker closes without a jump", function()
+    vim.api.nvim_win_set_cursor(win, { 12, 0 })
+    run(function()
+      vim.api.nvim_win_set_cursor(win, { 50, 0 })
+    end)
+    assert.is_true(ctrl:is_closed())
+    assert.are.same({ 12, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+end)
@@ -0,0 +1,186 @@
+--- Tests for lua/scopes/pickers/native.lua
+--- Drives the built-in picker through its view and prompt buffer; typing is
+--- simulated by setting the prompt line and firing TextChangedI.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local Navigator = require("scopes.navigator")
+local Controller = require("scopes.controller")
+local native = require("scopes.pickers.native")
+local config = require("scopes.config")
+
+--- Build a tree: root > { HandleRequest > { req, Validate }, main > { x } }.
+--- @param bufnr number
+--- @return ScopeTree, table
+local function make_test_tree(bufnr)
+  local function node(name, kind, start_row, end_row)
+    return ScopeNode.new({
+      name = name,
+      kind = kind,
+      range = { start_row = start_row, start_col = 0, end_row = end_row, end_col = 1 },
+    })
+  end
+  local root = node("sample.go", "module", 0, 99)
+  local handle = node("HandleRequest", "function", 5, 30)
+  local req = node("req", "variable", 6, 6)
+  local validate = node("Validate", "function", 10, 20)
+  local main_fn = node("main", "function", 40, 60)
+  local x = node("x", "variable", 41, 41)
+  root:add_child(handle)
+  root:add_child(main_fn)
+  handle:add_child(req)
+  handle:add_child(validate)
+  main_fn:add_child(x)
+  local scope_tree = tree_mod.ScopeTree.new({ root = root, source = "treesitter", bufnr = bufnr, lang = "go" })
+  return scope_tree, { root = root, handle = handle, req = req, validate = validate, main = main_fn, x = x }
+end
+
+describe("native picker", function()
+  local bufnr, win, nodes, ctrl, view
+
+  --- Open the picker, optionally with picker config overrides.
+  local function open(picker_opts)
+    config.merge({ picker = picker_opts or {} })
+    local scope_tree
+    scope_tree, nodes = make_test_tree(bufnr)
+    ctrl = Controller.new(Navigator.new(scope_tree), bufnr)
+    assert.is_true(ctrl:attach(native))
+    view = ctrl.view
+  end
+
+  --- Type `query` into the prompt.
+  local function type_query(query)
+    vim.api.nvim_buf_set_lines(view.prompt_buf, 0, -1, false, { query })
+    vim.api.nvim_exec_autocmds("TextChangedI", { buffer = view.prompt_buf })
+  end
+
+  local function list_lines()
+    return vim.api.nvim_buf_get_lines(view.list_buf, 0, -1, false)
+  end
+
+  before_each(function()
+    bufnr = vim.api.nvim_create_buf(false, true)
+    local lines = {}
+    for i = 1, 100 do
+      lines[i] = "line " .. i
+    end
+    vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 50, 0 })
+  end)
+
+  after_each(function()
+    if view then
+      view.close()
+    end
+    view = nil
+    config.merge({})
+    vim.api.nvim_buf_delete(bufnr, { force = true })
+  end)
+
+  it("opens a prompt, a list and a preview float", function()
+    open()
+    assert.are.equal(view.prompt_win, vim.api.nvim_get_current_win())
+    assert.are.equal("editor", vim.api.nvim_win_get_config(view.list_win).relative)
+    assert.are.equal(bufnr, vim.api.nvim_win_get_buf(view.preview_win))
+  end)
+
+  it("has no preview float with picker.preview = false", function()
+    open({ preview = false })
+    assert.is_nil(view.preview_win)
+  end)
+
+  it("lists the current level with names and kinds", function()
+    open({ preview = false })
+    local lines = list_lines()
+    assert.are.equal(2, #lines)
+    assert.is_truthy(lines[1]:find("HandleRequest [function] :6", 1, true))
+    assert.is_truthy(lines[2]:find("main [function] :41", 1, true))
+  end)
+
+  it("filters fuzzily as the query changes", function()
+    open({ preview = false })
+    type_query("mn")
+    assert.are.same({ 2 }, view.filtered)
+    assert.are.equal(nodes.main, view.selected_node())
+    type_query("")
+    assert.are.same({ 1, 2 }, view.filtered)
+  end)
+
+  it("lists nothing when no item matches", function()
+    open({ preview = false })
+    type_query("zzz")
+    assert.are.same({}, view.filtered)
+    assert.is_nil(view.selected_node())
+  end)
+
+  it("focus() selects an item and moves the list cursor", function()
+    open({ preview = false })
+    view:focus(2)
+    assert.are.equal(nodes.main, view.selected_node())
+    assert.are.equal(2, vim.api.nvim_win_get_cursor(view.list_win)[1])
+  end)
+
+  it("shows the selected node in the preview", function()
+    open()
+    view:focus(2)
+    assert.are.equal(41, vim.api.nvim_win_get_cursor(view.preview_win)[1])
+  end)
+
+  it("drills down and retitles the prompt", function()
+    open({ preview = false })
+    ctrl:drill(nodes.handle)
+    local lines = list_lines()
+    assert.are.equal(2, #lines)
+    assert.is_truthy(lines[1]:find("req", 1, true))
+    local title = vim.api.nvim_win_get_config(view.prompt_win).title
+    assert.are.equal(" sample.go > HandleRequest ", title[1][1])
+  end)
+
+  it("goes up and selects the scope just left", function()
+    open({ preview = false })
+    ctrl:drill(nodes.main)
+    ctrl:up()
+    assert.are.equal(nodes.main, view.selected_node())
+  end)
+
+  it("lists the breadcrumb's levels on picker.levels", function()
+    open({ preview = false })
+    ctrl:drill(nodes.handle)
+    vim.fn.maparg("<C-b>", "i", false, true).callback()
+    local lines = list_lines()
+    assert.are.equal(2, #lines)
+    assert.is_truthy(lines[1]:find("sample.go", 1, true))
+    assert.are.equal(nodes.handle, view.selected_node())
+  end)
+
+  it("matches recursive results on their path", function()
+    open({ preview = false })
+    ctrl:toggle_recursive()
+    assert.are.equal(5, #list_lines())
+    -- Validate is found by the name of the scope it is nested in.
+    type_query("Handle Validate")
+    assert.are.same({ 3 }, view.filtered)
+    assert.is_truthy(list_lines()[1]:find("HandleRequest > Validate [function]", 1, true))
+  end)
+
+  it("closes every float and restores the cursor on close", function()
+    open()
+    local wins = { view.prompt_win, view.list_win, view.preview_win }
+    view.close()
+    for _, w in ipairs(wins) do
+      assert.is_false(vim.api.nvim_win_is_valid(w))
+    end
+    assert.is_true(ctrl:is_closed())
+    assert.are.equal(win, vim.api.nvim_get_current_win())
+    assert.are.same({ 50, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+
+  it("confirm closes the picker and jumps to the node", function()
+    open()
+    ctrl:confirm(nodes.main)
+    assert.is_false(vim.api.nvim_win_is_valid(view.prompt_win))
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+end)
@@ -0,0 +1,286 @@
+--- Tests for lua/scopes/pickers/telescope.lua
+--- telescope.nvim is not installed in the test env, so its modules are stubbed
+--- through package.loaded. The stub records the finder, the prompt titles and
+--- the mapped actions; tests call the mapped actions directly. Like
+--- Telescope, it populates the results from vim.schedule.
+
+local tree_mod = require("scopes.tree")
+local ScopeNode = tree_mod.ScopeNode
+local Navigator = require("scopes.navigator")
+local Controller = require("scopes.controller")
+local telescope = require("scopes.pickers.telescope")
+
+local MODULES = {
+  "telescope",
+  "telescope.pickers",
+  "telescope.finders",
+  "telescope.config",
+  "telescope.actions",
+  "telescope.actions.state",
+}
+
+--- Build a tree: root > { HandleRequest > { req, Validate }, main > { x } }.
+--- @param bufnr number
+--- @return ScopeTree, table
+local function make_test_tree(bufnr)
+  local function node(name, kind, start_row, end_row)
+    return ScopeNode.new({
+      name = name,
+      kind = kind,
+      range = { start_row = start_row, start_col = 0, end_row = end_row, end_col = 1 },
+    })
+  end
+  local root = node("sample.go", "module", 0, 99)
+  local handle = node("HandleRequest", "function", 5, 30)
+  local req = node("req", "variable", 6, 6)
+  local validate = node("Validate", "function", 10, 20)
+  local main_fn = node("main", "function", 40, 60)
+  local x = node("x", "variable", 41, 41)
+  root:add_child(handle)
+  root:add_child(main_fn)
+  handle:add_child(req)
+  handle:add_child(validate)
+  main_fn:add_child(x)
+  local scope_tree = tree_mod.ScopeTree.new({ root = root, source = "treesitter", bufnr = bufnr, lang = "go" })
+  return scope_tree, { root = root, handle = handle, req = req, validate = validate, main = main_fn, x = x }
+end
+
+--- Install telescope stubs into package.loaded.
+--- @return table state  { spec, finder, titles, maps, selected, selected_row, closed, previewed }
+local function stub_telescope()
+  local state = { titles = {}, maps = {}, closed = false }
+
+  local picker = { prompt_bufnr = 1 }
+  picker.prompt_border = {
+    change_title = function(_, title)
+      table.insert(state.titles, title)
+    end,
+  }
+  picker.manager = {
+    iter = function()
+      local i = 0
+      return function()
+        i = i + 1
+        local node = state.finder.results[i]
+        return node and state.finder.entry_maker(node)
+      end
+    end,
+  }
+  function picker:get_row(index)
+    return index
+  end
+  function picker:set_selection(row)
+    state.selected_row = row
+  end
+  function picker:register_completion_callback(fn)
+    state.on_complete = fn
+  end
+  local function complete()
+    if state.on_complete then
+      state.on_complete(picker)
+    end
+  end
+  function picker:refresh(finder)
+    state.finder = finder
+    vim.schedule(complete)
+  end
+  function picker:find()
+    state.spec.attach_mappings(self.prompt_bufnr, function(mode, key, fn)
+      state.maps[mode .. key] = fn
+    end)
+    vim.schedule(complete)
+  end
+
+  local close = setmetatable({
+    enhance = function(_, hooks)
+      state.on_close = hooks.post
+    end,
+  }, {
+    __call = function()
+      state.closed = true
+      if state.on_close then
+        state.on_close()
+      end
+    end,
+  })
+
+  package.loaded["telescope"] = {}
+  package.loaded["telescope.pickers"] = {
+    new = function(_, spec)
+      state.spec = spec
+      state.finder = spec.finder
+      return picker
+    end,
+  }
+  package.loaded["telescope.finders"] = {
+    new_table = function(opts)
+      return opts
+    end,
+  }
+  package.loaded["telescope.config"] = {
+    values = {
+      generic_sorter = function()
+        return {}
+      end,
+      qflist_previewer = function()
+        return {
+          preview = function(_, entry)
+            state.previewed = entry
+          end,
+        }
+      end,
+    },
+  }
+  package.loaded["telescope.actions"] = {
+    close = close,
+    select_default = {
+      replace = function(_, fn)
+        state.maps.default = fn
+      end,
+    },
+  }
+  package.loaded["telescope.actions.state"] = {
+    get_selected_entry = function()
+      return state.selected
+    end,
+  }
+  return state
+end
+
+describe("telescope picker", function()
+  local bufnr, win, nodes, ctrl, state
+
+  --- Open the picker on a controller over the test tree.
+  --- @param nav_opts? table  passed to Navigator.new
+  local function open(nav_opts)
+    local scope_tree
+    scope_tree, nodes = make_test_tree(bufnr)
+    ctrl = Controller.new(Navigator.new(scope_tree, nav_opts), bufnr)
+    assert.is_true(ctrl:attach(telescope))
+  end
+
+  --- Highlight `node` in the stubbed results list.
+  local function select(node)
+    state.selected = state.finder.entry_maker(node)
+  end
+
+  --- Wait for the scheduled population to select a row.
+  --- @return number|nil
+  local function selected_row()
+    vim.wait(200, function()
+      return state.selected_row ~= nil
+    end)
+    return state.selected_row
+  end
+
+  before_each(function()
+    bufnr = vim.api.nvim_create_buf(false, true)
+    local lines = {}
+    for i = 1, 100 do
+      lines[i] = "line " .. i
+    end
+    vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, lines)
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 1, 0 })
+    state = stub_telescope()
+  end)
+
+  after_each(function()
+    for _, name in ipairs(MODULES) do
+      package.loaded[name] = nil
+    end
+    vim.cmd("only")
+    vim.api.nvim_buf_delete(bufnr, { force = true })
+  end)
+
+  it("opens the current level under the breadcrumb title", function()
+    open()
+    assert.are.equal("sample.go", state.spec.prompt_title)
+    assert.are.same({ nodes.handle, nodes.main }, state.finder.results)
+  end)
+
+  it("starts on the item under the cursor", function()
+    open({ cursor_row = 10 })
+    assert.are.same({ nodes.req, nodes.validate }, state.finder.results)
+    assert.are.equal(2, selected_row())
+  end)
+
+  it("drills down on the drill key and retitles the prompt", function()
+    open()
+    select(nodes.handle)
+    state.maps["i<Tab>"]()
+    assert.are.equal(nodes.handle, ctrl.nav:current())
+    assert.are.same({ nodes.req, nodes.validate }, state.finder.results)
+    assert.are.equal("sample.go > HandleRequest", state.titles[#state.titles])
+  end)
+
+  it("goes up on the go-up key and selects the scope just left", function()
+    open()
+    select(nodes.main)
+    state.maps["n<Tab>"]()
+    state.maps["n<S-Tab>"]()
+    assert.are.equal(nodes.root, ctrl.nav:current())
+    assert.are.same({ nodes.handle, nodes.main }, state.finder.results)
+    assert.are.equal(2, selected_row())
+    assert.are.equal("sample.go", state.titles[#state.titles])
+  end)
+
+  it("jumps to the selection in the current window", function()
+    open()
+    select(nodes.main)
+    state.maps.default()
+    assert.is_true(state.closed)
+    assert.is_true(ctrl:is_closed())
+    assert.are.equal(1, #vim.api.nvim_tabpage_list_wins(0))
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(win))
+  end)
+
+  it("opens a vertical split on the split_vertical key", function()
+    open()
+    select(nodes.main)
+    state.maps["i<C-v>"]()
+    assert.is_true(state.closed)
+    assert.are.equal("row", vim.fn.winlayout()[1])
+    assert.are_not.equal(win, vim.api.nvim_get_current_win())
+    assert.are.same({ 41, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("opens a horizontal split on the split_horizontal key", function()
+    open()
+    select(nodes.handle)
+    state.maps["i<C-s>"]()
+    assert.is_true(state.closed)
+    assert.are.equal("col", vim.fn.winlayout()[1])
+    assert.are.same({ 6, 0 }, vim.api.nvim_win_get_cursor(0))
+  end)
+
+  it("shows the refreshed items and selects the cursor's item on refresh()", function()
+    open({ cursor_row = 10 })
+    selected_row()
+    state.selected_row = nil
+    local extra = ScopeNode.new({
+      name = "extra",
+      kind = "variable",
+      range = { start_row = 25, start_col = 0, end_row = 25, end_col = 1 },
+    })
+    nodes.handle:add_child(extra)
+    ctrl:refresh({ focus_cursor = true })
+    assert.are.same({ nodes.req, nodes.validate, extra }, state.finder.results)
+    assert.are.equal(2, selected_row())
+  end)
+
+  it("peeks at the previewed node in the main window", function()
+    open()
+    local entry = state.finder.entry_maker(nodes.main)
+    state.spec.previewer:preview(entry, {})
+    assert.are.equal(entry, state.previewed)
+    assert.are.equal(41, vim.api.nvim_win_get_cursor(win)[1])
+  end)
+
+  it("reports a close without a jump to the controller", function()
+    open()
+    package.loaded["telescope.actions"].close(1)
+    assert.is_true(ctrl:is_closed())
+  end)
+end)
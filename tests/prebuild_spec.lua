@@ -0,0 +1,79 @@
+local prebuild = require("scopes.prebuild")
+local tree_mod = require("scopes.tree")
+local config = require("scopes.config")
+local helpers = require("tests.helpers")
+
+--- Create a listed, file-like Go buffer (scratch buffers are skipped by prebuild).
+local function make_file_buf()
+  local bufnr = vim.api.nvim_create_buf(true, false)
+  vim.api.nvim_buf_set_lines(bufnr, 0, -1, false, vim.fn.readfile("tests/fixtures/sample.go"))
+  vim.api.nvim_set_option_value("filetype", "go", { buf = bufnr })
+  vim.treesitter.start(bufnr, "go")
+  return bufnr
+end
+
+describe("prebuild", function()
+  local bufnr
+
+  before_each(function()
+    config.merge({ backend = "treesitter", cache = { debounce_ms = 20 } })
+    bufnr = make_file_buf()
+    tree_mod.clear(bufnr)
+    tree_mod.reset_cache_stats()
+  end)
+
+  after_each(function()
+    prebuild.stop()
+    tree_mod.clear(bufnr)
+    helpers.delete_buf(bufnr)
+    config.merge({})
+  end)
+
+  it("build_now() warms the cache", function()
+    prebuild.build_now(bufnr)
+    tree_mod.reset_cache_stats()
+    tree_mod.build(bufnr)
+    assert.are.equal(1, tree_mod.cache_stats().hits)
+  end)
+
+  it("skips scratch buffers", function()
+    local scratch = helpers.make_buf("tests/fixtures/sample.go", "go")
+    prebuild.build_now(scratch)
+    assert.are.equal(0, tree_mod.cache_stats().misses)
+    helpers.delete_buf(scratch)
+  end)
+
+  it("schedule() builds once after the debounce delay", function()
+    prebuild.schedule(bufnr)
+    prebuild.schedule(bufnr)
+    prebuild.schedule(bufnr)
+    assert.are.equal(0, tree_mod.cache_stats().misses)
+    assert.is_true(vim.wait(1000, function()
+      return tree_mod.cache_stats().misses > 0
+    end))
+    vim.wait(100)
+    assert.are.equal(1, tree_mod.cache_stats().misses)
+  end)
+
+  it("cancel() stops a pending build", function()
+    prebuild.schedule(bufnr)
+    prebuild.cancel(bufnr)
+    vim.wait(100)
+    assert.are.equal(0, tree_mod.cache_stats().misses)
+  end)
+
+  it("builds on BufEnter once started", function()
+    prebuild.start()
+    vim.api.nvim_exec_autocmds("BufEnter", { buffer = bufnr })
+    tree_mod.reset_cache_stats()
+    tree_mod.build(bufnr)
+    assert.are.equal(1, tree_mod.cache_stats().hits)
+  end)
+
+  it("stop() removes the autocmds", function()
+    prebuild.start()
+    prebuild.stop()
+    vim.api.nvim_exec_autocmds("BufEnter", { buffer = bufnr })
+    assert.are.equal(0, tree_mod.cache_stats().misses)
+  end)
+end)
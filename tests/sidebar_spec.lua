@@ -0,0 +1,174 @@
+--- Tests for lua/scopes/sidebar.lua
+--- Uses the Go fixture with the Treesitter backend; the fixture is small
+--- enough to be built synchronously, so the outline is ready on open().
+
+local helpers = require("tests.helpers")
+local config = require("scopes.config")
+local tree_mod = require("scopes.tree")
+local sidebar = require("scopes.sidebar")
+
+describe("sidebar", function()
+  local bufnr, win
+
+  local function lines()
+    return vim.api.nvim_buf_get_lines(sidebar.info().buf, 0, -1, false)
+  end
+
+  --- Return the last sidebar line whose name is `name`, as a line number.
+  --- (The last, since `package main` may be listed before `func main`.)
+  local function line_of(name)
+    local found = nil
+    for i, line in ipairs(lines()) do
+      if line:sub(-#name - 1) == " " .. name then
+        found = i
+      end
+    end
+    return found
+  end
+
+  --- Put the sidebar cursor on the line of `name`.
+  local function select(name)
+    vim.api.nvim_win_set_cursor(sidebar.info().win, { assert(line_of(name), name), 0 })
+  end
+
+  --- Return the line highlighted as the cursor's scope.
+  local function current_line()
+    local ns = vim.api.nvim_create_namespace("scopes_sidebar_current")
+    local marks = vim.api.nvim_buf_get_extmarks(sidebar.info().buf, ns, 0, -1, {})
+    return marks[1] and lines()[marks[1][2] + 1]
+  end
+
+  before_each(function()
+    config.merge({ backend = "treesitter", display = { icons = false } })
+    bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+    win = vim.api.nvim_get_current_win()
+    vim.api.nvim_win_set_buf(win, bufnr)
+    vim.api.nvim_win_set_cursor(win, { 1, 0 })
+  end)
+
+  after_each(function()
+    sidebar.close()
+    tree_mod.clear(bufnr)
+    helpers.delete_buf(bufnr)
+    config.merge({})
+  end)
+
+  it("toggle() opens a split with the top-level outline and keeps focus", function()
+    sidebar.toggle()
+    assert.is_true(sidebar.is_open())
+    assert.are.equal(win, vim.api.nvim_get_current_win())
+    assert.is_not_nil(line_of("HandleRequest"))
+    assert.is_not_nil(line_of("main"))
+    assert.are.equal("▸ main", lines()[line_of("main")])
+  end)
+
+  it("toggle() closes an open sidebar", function()
+    sidebar.toggle()
+    local sidebar_win = sidebar.info().win
+    sidebar.toggle()
+    assert.is_false(sidebar.is_open())
+    assert.is_false(vim.api.nvim_win_is_valid(sidebar_win))
+  end)
+
+  it("opens at the configured width", function()
+    config.merge({ backend = "treesitter", sidebar = { width = 28 } })
+    sidebar.open()
+    assert.are.equal(28, vim.api.nvim_win_get_width(sidebar.info().win))
+  end)
+
+  it("jump() moves the source window to the symbol", function()
+    sidebar.open()
+    vim.api.nvim_set_current_win(sidebar.info().win)
+    select("main")
+    sidebar.jump()
+    assert.are.equal(win, vim.api.nvim_get_current_win())
+    assert.are.equal(79, vim.api.nvim_win_get_cursor(win)[1])
+  end)
+
+  it("toggle_fold() expands a scope and collapses it again", function()
+    sidebar.open()
+    local count = #lines()
+    select("HandleRequest")
+    sidebar.toggle_fold()
+    assert.is_true(#lines() > count)
+    local handle_line = line_of("HandleRequest")
+    assert.is_truthy(lines()[handle_line]:find("▾", 1, true))
+    assert.is_truthy(lines()[handle_line + 1]:match("^  "), "children are indented")
+
+    select("HandleRequest")
+    sidebar.toggle_fold()
+    assert.are.equal(count, #lines())
+  end)
+
+  it("toggle_fold() on a child collapses its scope, like go-up", function()
+    sidebar.open()
+    select("HandleRequest")
+    sidebar.toggle_fold()
+    local handle_line = line_of("HandleRequest")
+    vim.api.nvim_win_set_cursor(sidebar.info().win, { handle_line + 1, 0 })
+    sidebar.toggle_fold()
+    assert.is_truthy(lines()[line_of("HandleRequest")]:find("▸", 1, true))
+    assert.are.equal(line_of("HandleRequest"), vim.api.nvim_win_get_cursor(sidebar.info().win)[1])
+  end)
+
+  it("does not expand a leaf", function()
+    sidebar.open()
+    local count = #lines()
+    local leaf = nil
+    for _, node in ipairs(tree_mod.build(bufnr).root.children) do
+      if not node:is_scope() then
+        leaf = node
+        break
+      end
+    end
+    assert.is_not_nil(leaf, "fixture has a top-level leaf")
+    select(leaf.name)
+    sidebar.drill_down()
+    assert.are.equal(count, #lines())
+  end)
+
+  it("highlights the cursor's deepest scope, expanding its parents", function()
+    sidebar.open()
+    -- On `m.Count++`, in `if action == "count"` in the for loop of HandleRequest.
+    vim.api.nvim_win_set_cursor(win, { 45, 3 })
+    vim.api.nvim_exec_autocmds("CursorMoved", {})
+    assert.is_truthy(lines()[line_of("HandleRequest")]:find("▾", 1, true))
+    local current = current_line()
+    assert.is_truthy(current)
+    assert.is_truthy(current:match("^    "), "the deepest scope is nested two levels")
+  end)
+
+  it("opens on a buffer without a parser", function()
+    local plain = vim.api.nvim_create_buf(false, true)
+    vim.api.nvim_buf_set_lines(plain, 0, -1, false, { "just text" })
+    vim.api.nvim_win_set_buf(win, plain)
+    local _, restore = helpers.capture_notify()
+    local ok, err = pcall(sidebar.open)
+    restore()
+    assert.is_true(ok, err)
+    assert.are.same({ "No scope tree for this buffer" }, lines())
+    vim.api.nvim_set_current_win(sidebar.info().win)
+    sidebar.toggle_fold()
+    sidebar.jump()
+    vim.api.nvim_set_current_win(win)
+    vim.api.nvim_win_set_buf(win, bufnr)
+    helpers.delete_buf(plain)
+  end)
+
+  it("re-renders after the tree cache is invalidated", function()
+    sidebar.open()
+    vim.api.nvim_buf_set_lines(bufnr, -1, -1, false, { "", "func Added() {}" })
+    tree_mod.invalidate(bufnr)
+    assert.is_not_nil(line_of("Added"))
+  end)
+
+  it("re-renders after edits", function()
+    config.merge({ backend = "treesitter", cache = { debounce_ms = 10 } })
+    sidebar.open()
+    vim.api.nvim_buf_set_lines(bufnr, -1, -1, false, { "", "func Edited() {}" })
+    vim.api.nvim_exec_autocmds("TextChanged", { buffer = bufnr })
+    assert.is_true(vim.wait(1000, function()
+      return line_of("Edited") ~= nil
+    end))
+  end)
+end)
@@ -72,6 +72,77 @@ describe("ScopeNode", function()
     end)
   end)
 
+  describe("defer / expand", function()
+    local function make_lazy()
+      local parent = ScopeNode.new({
+        name = "f",
+        kind = "function",
+        range = { start_row = 0, start_col = 0, end_row = 10, end_col = 0 },
+      })
+      local calls = 0
+      parent:defer(function()
+        calls = calls + 1
+        parent:add_child(ScopeNode.new({
+          name = "x",
+          kind = "variable",
+          range = { start_row = 1, start_col = 0, end_row = 1, end_col = 5 },
+        }))
+      end, true)
+      return parent, function()
+        return calls
+      end
+    end
+
+    it("is_scope() reports the deferred answer before expansion", function()
+      local parent = make_lazy()
+      assert.are.equal(0, #parent.children)
+      assert.is_true(parent:is_scope())
+      assert.is_true(parent:is_deferred())
+    end)
+
+    it("expand() builds the children once", function()
+      local parent, calls = make_lazy()
+      assert.are.equal(1, #parent:expand())
+      assert.are.equal(1, #parent:expand())
+      assert.are.equal(1, calls())
+      assert.is_false(parent:is_deferred())
+      assert.is_true(parent:is_scope())
+    end)
+
+    it("expand() returns children as is for an eager node", function()
+      local node = ScopeNode.new({
+        name = "x",
+        kind = "variable",
+        range = { start_row = 0, start_col = 0, end_row = 0, end_col = 1 },
+      })
+      assert.are.same({}, node:expand())
+    end)
+  end)
+
+  describe("contains", function()
+    local node = ScopeNode.new({
+      name = "f",
+      kind = "function",
+      range = { start_row = 2, start_col = 4, end_row = 5, end_col = 1 },
+    })
+
+    it("returns true for a range strictly inside", function()
+      assert.is_true(node:contains({ start_row = 3, start_col = 0, end_row = 4, end_col = 9 }))
+    end)
+
+    it("returns true for an identical range", function()
+      assert.is_true(node:contains({ start_row = 2, start_col = 4, end_row = 5, end_col = 1 }))
+    end)
+
+    it("returns false when the range starts before the node on the same row", function()
+      assert.is_false(node:contains({ start_row = 2, start_col = 0, end_row = 3, end_col = 0 }))
+    end)
+
+    it("returns false when the range ends after the node", function()
+      assert.is_false(node:contains({ start_row = 3, start_col = 0, end_row = 6, end_col = 0 }))
+    end)
+  end)
+
   describe("validation", function()
     local warnings, restore_notify
 
@@ -566,6 +637,106 @@ describe("find_scope_for_row", function()
     local result = tree_mod.find_scope_for_row(st, 40)
     assert.are.equal("funcB", result.name)
   end)
+
+  it("compares columns when col is given", function()
+    local st = make_row_tree()
+    -- nested starts at row 10 col 2; col 0 on that row is only inside funcA
+    assert.are.equal("funcA", tree_mod.find_scope_for_row(st, 10, 0).name)
+    assert.are.equal("nested", tree_mod.find_scope_for_row(st, 10, 2).name)
+  end)
+end)
+
+describe("ScopeTree:node_at", function()
+  local tree_mod = require("scopes.tree")
+  local ScopeNode = tree_mod.ScopeNode
+  local ScopeTree = tree_mod.ScopeTree
+
+  local function node(name, sr, sc, er, ec)
+    return ScopeNode.new({
+      name = name,
+      kind = "function",
+      range = { start_row = sr, start_col = sc, end_row = er, end_col = ec },
+    })
+  end
+
+  --- root (0–99) with `count` siblings of 3 rows each; every sibling holds a
+  --- single-line leaf on its middle row.
+  local function make_wide_tree(count)
+    local root = node("root", 0, 0, 99 + count * 3, 0)
+    for i = 0, count - 1 do
+      local fn = node("f" .. i, i * 3, 0, i * 3 + 2, 1)
+      fn:add_child(node("x" .. i, i * 3 + 1, 2, i * 3 + 1, 8))
+      root:add_child(fn)
+    end
+    return ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+  end
+
+  local function chain_names(chain)
+    return vim.tbl_map(function(n)
+      return n.name
+    end, chain)
+  end
+
+  it("returns the deepest node and the chain from the root", function()
+    local st = make_wide_tree(500)
+    local found, chain = st:node_at(1000)
+    assert.are.equal("x333", found.name)
+    assert.are.same({ "root", "f333", "x333" }, chain_names(chain))
+  end)
+
+  it("returns the root alone when no child contains the row", function()
+    local st = make_wide_tree(10)
+    local found, chain = st:node_at(50)
+    assert.are.equal(st.root, found)
+    assert.are.same({ "root" }, chain_names(chain))
+  end)
+
+  it("compares columns when col is given", function()
+    local st = make_wide_tree(10)
+    assert.are.equal("x3", (st:node_at(10, 4)).name)
+    assert.are.equal("f3", (st:node_at(10, 0)).name)
+    -- end_col is exclusive
+    assert.are.equal("f3", (st:node_at(10, 8)).name)
+  end)
+
+  it("finds a long sibling that overlaps later, shorter ones", function()
+    local root = node("root", 0, 0, 50, 0)
+    root:add_child(node("long", 0, 0, 40, 0))
+    root:add_child(node("short", 5, 0, 6, 0))
+    local st = ScopeTree.new({ root = root, source = "lsp", bufnr = 1, lang = "go" })
+    assert.are.equal("long", (st:node_at(20)).name)
+    assert.are.equal("short", (st:node_at(5)).name)
+  end)
+
+  it("takes the first of several siblings starting on the row when col is nil", function()
+    local root = node("root", 0, 0, 50, 0)
+    root:add_child(node("a", 3, 4, 3, 19))
+    root:add_child(node("b", 3, 21, 3, 36))
+    local st = ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+    assert.are.equal("a", (st:node_at(3)).name)
+    assert.are.equal("b", (st:node_at(3, 25)).name)
+  end)
+
+  it("sees children added after a previous lookup", function()
+    local root = node("root", 0, 0, 50, 0)
+    root:add_child(node("a", 0, 0, 5, 0))
+    local st = ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+    assert.are.equal(root, (st:node_at(20)))
+    root:add_child(node("b", 10, 0, 30, 0))
+    assert.are.equal("b", (st:node_at(20)).name)
+  end)
+
+  it("expands deferred nodes along the path", function()
+    local root = node("root", 0, 0, 50, 0)
+    local outer = node("outer", 10, 0, 30, 0)
+    outer:defer(function()
+      outer:add_child(node("inner", 12, 0, 14, 0))
+    end, true)
+    root:add_child(outer)
+    local st = ScopeTree.new({ root = root, source = "treesitter", bufnr = 1, lang = "go" })
+    local _, chain = st:node_at(13)
+    assert.are.same({ "root", "outer", "inner" }, chain_names(chain))
+  end)
 end)
 
 describe("build", function()
@@ -589,15 +760,28 @@ describe("build", function()
     assert.are.equal("treesitter", result.source)
   end)
 
-  it("returns nil for a buffer with no parser", function()
-    local warnings, restore = helpers.capture_notify()
+  it("returns nil for a buffer with no parser when backend = treesitter", function()
+    local _, restore = helpers.capture_notify()
     local empty_bufnr = vim.api.nvim_create_buf(false, true)
-    local result = tree_mod.build(empty_bufnr)
+    local result = tree_mod.build(empty_bufnr, { backend = "treesitter" })
     restore()
     assert.is_nil(result)
     vim.api.nvim_buf_delete(empty_bufnr, { force = true })
   end)
 
+  it("auto falls back to the indent backend when there is no parser or LSP", function()
+    local warnings, restore = helpers.capture_notify()
+    local plain_bufnr = vim.api.nvim_create_buf(false, true)
+    vim.api.nvim_buf_set_lines(plain_bufnr, 0, -1, false, { "todo", "  buy milk" })
+    local result = tree_mod.build(plain_bufnr, { backend = "auto" })
+    restore()
+    assert.is_not_nil(result)
+    assert.are.equal("indent", result.source)
+    assert.are.same({ "todo" }, helpers.child_names(result.root))
+    assert.are.same({}, warnings, "the fallback is silent")
+    vim.api.nvim_buf_delete(plain_bufnr, { force = true })
+  end)
+
   it("returns nil and emits a WARN for backend = lsp", function()
     local warnings, restore = helpers.capture_notify()
     local result = tree_mod.build(bufnr, { backend = "lsp" })
@@ -612,6 +796,45 @@ describe("build", function()
     assert.is_not_nil(result)
     assert.are.equal("treesitter", result.source)
   end)
+
+  describe("with a fake LSP client", function()
+    local symbols = {
+      {
+        name = "Thing",
+        kind = vim.lsp.protocol.SymbolKind.Function,
+        range = { start = { line = 0, character = 0 }, ["end"] = { line = 0, character = 4 } },
+        selectionRange = { start = { line = 0, character = 0 }, ["end"] = { line = 0, character = 4 } },
+      },
+    }
+    local lsp_bufnr, client_id
+
+    before_each(function()
+      lsp_bufnr = vim.api.nvim_create_buf(true, false)
+      vim.api.nvim_buf_set_name(lsp_bufnr, vim.fn.tempname() .. ".unknown_xyz")
+      vim.api.nvim_buf_set_lines(lsp_bufnr, 0, -1, false, { "Thing" })
+      client_id = helpers.start_fake_lsp(lsp_bufnr, { ["textDocument/documentSymbol"] = symbols })
+    end)
+
+    after_each(function()
+      helpers.stop_fake_lsp(client_id)
+      helpers.delete_buf(lsp_bufnr)
+    end)
+
+    it("uses the LSP backend when opts.backend = lsp", function()
+      local result = tree_mod.build(lsp_bufnr, { backend = "lsp" })
+      assert.is_not_nil(result)
+      assert.are.equal("lsp", result.source)
+      assert.are.same({ "Thing" }, helpers.child_names(result.root))
+    end)
+
+    it("auto falls back to LSP when treesitter has no parser", function()
+      local _, restore = helpers.capture_notify()
+      local result = tree_mod.build(lsp_bufnr, { backend = "auto" })
+      restore()
+      assert.is_not_nil(result)
+      assert.are.equal("lsp", result.source)
+    end)
+  end)
 end)
 
 describe("cache", function()
@@ -620,12 +843,13 @@ describe("cache", function()
   local bufnr
 
   before_each(function()
-    config.merge({ cache = { enabled = true, debounce_ms = 300 } })
+    config.merge({ cache = { enabled = true } })
     bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
     tree_mod.invalidate(bufnr)
   end)
 
   after_each(function()
+    tree_mod.clear(bufnr)
     helpers.delete_buf(bufnr)
   end)
 
@@ -635,6 +859,48 @@ describe("cache", function()
     assert.are.equal(t1, t2)
   end)
 
+  it("keeps returning the same tree while the buffer is unchanged", function()
+    local t1 = tree_mod.build(bufnr)
+    vim.wait(350)
+    assert.are.equal(t1, tree_mod.build(bufnr))
+  end)
+
+  it("rebuilds after the buffer changes without any autocmd", function()
+    local t1 = tree_mod.build(bufnr)
+    vim.api.nvim_buf_set_lines(bufnr, -1, -1, false, { "func added() {}" })
+    local t2 = tree_mod.build(bufnr)
+    assert.are_not.equal(t1, t2)
+    assert.are.equal(1, #helpers.find_by_name(t2.root, "added"))
+  end)
+
+  it("keys entries on the backend", function()
+    local t1 = tree_mod.build(bufnr, { backend = "treesitter" })
+    local t2 = tree_mod.build(bufnr, { backend = "indent" })
+    assert.are_not.equal(t1, t2)
+    assert.are.equal(t1, tree_mod.build(bufnr, { backend = "treesitter" }))
+  end)
+
+  it("evicts the least recently used tree beyond cache.max_entries", function()
+    config.merge({ cache = { enabled = true, max_entries = 1 } })
+    local other = helpers.make_buf("tests/fixtures/sample.lua", "lua")
+    local t1 = tree_mod.build(bufnr)
+    tree_mod.build(other)
+    assert.are.equal(1, tree_mod.cache_stats().entries)
+    assert.are_not.equal(t1, tree_mod.build(bufnr))
+    tree_mod.clear(other)
+    helpers.delete_buf(other)
+  end)
+
+  it("counts hits and misses", function()
+    tree_mod.reset_cache_stats()
+    tree_mod.build(bufnr)
+    tree_mod.build(bufnr)
+    tree_mod.build(bufnr)
+    local stats = tree_mod.cache_stats()
+    assert.are.equal(1, stats.misses)
+    assert.are.equal(2, stats.hits)
+  end)
+
   it("returns a new object after invalidate() (cache miss)", function()
     local t1 = tree_mod.build(bufnr)
     tree_mod.invalidate(bufnr)
@@ -642,14 +908,101 @@ describe("cache", function()
     assert.are_not.equal(t1, t2)
   end)
 
+  it("fires User ScopesInvalidated from invalidate()", function()
+    local seen = nil
+    local id = vim.api.nvim_create_autocmd("User", {
+      pattern = "ScopesInvalidated",
+      callback = function(ev)
+        seen = ev.data.buf
+      end,
+    })
+    tree_mod.invalidate(bufnr)
+    vim.api.nvim_del_autocmd(id)
+    assert.are.equal(bufnr, seen)
+  end)
+
+  it("reuses unchanged nodes when rebuilding after invalidate()", function()
+    local t1 = tree_mod.build(bufnr)
+    local first = t1.root.children[1]
+    tree_mod.invalidate(bufnr)
+    local t2 = tree_mod.build(bufnr)
+    assert.are.equal(first, t2.root.children[1])
+  end)
+
+  it("builds from scratch after clear()", function()
+    local t1 = tree_mod.build(bufnr)
+    local first = t1.root.children[1]
+    tree_mod.clear(bufnr)
+    local t2 = tree_mod.build(bufnr)
+    assert.are_not.equal(first, t2.root.children[1])
+  end)
+
   it("always builds fresh when cache.enabled = false", function()
-    config.merge({ cache = { enabled = false, debounce_ms = 300 } })
+    config.merge({ cache = { enabled = false } })
     local t1 = tree_mod.build(bufnr)
     local t2 = tree_mod.build(bufnr)
     assert.are_not.equal(t1, t2)
   end)
 end)
 
+describe("build_async", function()
+  local tree_mod = require("scopes.tree")
+  local config = require("scopes.config")
+  local bufnr
+
+  before_each(function()
+    bufnr = helpers.make_buf("tests/fixtures/sample.go", "go")
+  end)
+
+  after_each(function()
+    config.merge({})
+    tree_mod.clear(bufnr)
+    helpers.delete_buf(bufnr)
+  end)
+
+  it("builds synchronously below async.min_lines", function()
+    config.merge({ backend = "treesitter" })
+    local finished
+    local result = tree_mod.build_async(bufnr, {
+      on_done = function(tree)
+        finished = tree
+      end,
+    })
+    assert.is_truthy(result)
+    assert.are.equal(result, finished)
+    assert.is_true(#result.root.children > 0)
+  end)
+
+  it("returns a tree that fills in later for large buffers", function()
+    config.merge({ backend = "treesitter", async = { min_lines = 1, chunk_size = 10 } })
+    local finished
+    local result = tree_mod.build_async(bufnr, {
+      on_done = function(tree)
+        finished = tree
+      end,
+    })
+    assert.is_nil(finished)
+    assert.is_true(vim.wait(5000, function()
+      return finished ~= nil
+    end))
+    assert.are.equal(result, finished)
+    assert.are.equal(finished, tree_mod.build(bufnr))
+  end)
+
+  it("is cancelled by invalidate()", function()
+    config.merge({ backend = "treesitter", async = { min_lines = 1, chunk_size = 10 } })
+    local finished = false
+    tree_mod.build_async(bufnr, {
+      on_done = function()
+        finished = true
+      end,
+    })
+    tree_mod.invalidate(bufnr)
+    vim.wait(200)
+    assert.is_false(finished)
+  end)
+end)
+
 describe("ScopeTree", function()
   describe("new", function()
     it("sets all fields from opts", function()